@@ -0,0 +1,306 @@
+package tscache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+	"tscache/consistenthash"
+)
+
+// Ring describes the membership of a peer pool and how keys are placed on it.
+type Ring struct {
+	Self   string                 `json:"self"`             // Self is the name of this node.
+	Nodes  []RingNode             `json:"nodes"`            // Nodes lists the members, sorted by name.
+	Points []consistenthash.Point `json:"points,omitempty"` // Points lists the virtual nodes of a hash ring, in ring order.
+}
+
+// RingNode describes one member of a peer pool.
+type RingNode struct {
+	Name         string  `json:"name"`          // Name is the address of the node.
+	Weight       int     `json:"weight"`        // Weight is the relative capacity of the node.
+	Healthy      bool    `json:"healthy"`       // Healthy is false while health checks keep the node out of routing.
+	VirtualNodes int     `json:"virtual_nodes"` // VirtualNodes is the number of points of the node on a hash ring.
+	Share        float64 `json:"share"`         // Share is the fraction of the hash space owned by the node on a hash ring.
+}
+
+// RingInspector is implemented by peer pools whose placement the admin API can show.
+type RingInspector interface {
+	// Ring returns the current membership and placement.
+	Ring() Ring
+	// Owner returns the name of the node responsible for key.
+	Owner(key string) (string, error)
+}
+
+var (
+	_ RingInspector = (*HTTPPool)(nil)
+	_ RingInspector = (*GRPCPool)(nil)
+)
+
+// pointer is implemented by placements that place keys on a ring of virtual nodes.
+type pointer interface {
+	Points() []consistenthash.Point
+}
+
+// newRing builds the Ring of a pool from its members and placement.
+// ejected, which may be nil, lists the members kept out of routing.
+func newRing(self string, nodes []*consistenthash.Node, placer Placer, ejected map[string]bool) Ring {
+	ring := Ring{Self: self, Nodes: make([]RingNode, 0, len(nodes))}
+	counts := make(map[string]int)
+	shares := make(map[string]float64)
+	if p, ok := placer.(pointer); ok {
+		ring.Points = p.Points()
+		for i, point := range ring.Points {
+			// A point owns the arc from the previous point, wrapping around the ring
+			var arc uint64
+			if i == 0 {
+				arc = uint64(point.Hash) + math.MaxUint32 + 1 - uint64(ring.Points[len(ring.Points)-1].Hash)
+			} else {
+				arc = uint64(point.Hash - ring.Points[i-1].Hash)
+			}
+			counts[point.Node]++
+			shares[point.Node] += float64(arc) / (math.MaxUint32 + 1)
+		}
+	}
+	for _, node := range nodes {
+		ring.Nodes = append(ring.Nodes, RingNode{
+			Name:         node.Name,
+			Weight:       max(node.Weight, 1),
+			Healthy:      !ejected[node.Name],
+			VirtualNodes: counts[node.Name],
+			Share:        shares[node.Name],
+		})
+	}
+	sort.Slice(ring.Nodes, func(i, j int) bool { return ring.Nodes[i].Name < ring.Nodes[j].Name })
+	return ring
+}
+
+// GroupInfo describes a group in the admin API.
+type GroupInfo struct {
+	Name   string                `json:"name"`
+	Stats  Stats                 `json:"stats"`
+	Caches map[string]CacheStats `json:"caches"` // Caches holds the statistics of each cache, keyed by cache name.
+}
+
+// KeyInfo describes a key cached on a node in the admin API.
+type KeyInfo struct {
+	Key    string     `json:"key"`
+	Cache  string     `json:"cache"`            // Cache is the name of the cache holding the key.
+	Size   int        `json:"size"`             // Size is the length of the value.
+	Value  []byte     `json:"value,omitempty"`  // Value is the value, included when inspecting a single key.
+	Expire *time.Time `json:"expire,omitempty"` // Expire is when the value expires; unset means never.
+	Stale  bool       `json:"stale,omitempty"`  // Stale is set for values served while being refreshed.
+	Owner  string     `json:"owner,omitempty"`  // Owner is the node responsible for the key, if the pool is known.
+}
+
+// newKeyInfo describes a cached value.
+func newKeyInfo(key string, which CacheType, v ByteView) KeyInfo {
+	info := KeyInfo{Key: key, Cache: which.String(), Size: v.Len(), Stale: v.Stale()}
+	if expire := v.Expire(); !expire.IsZero() {
+		info.Expire = &expire
+	}
+	return info
+}
+
+// AdminHandler serves a JSON API to inspect and operate the groups of this node:
+//
+//	GET    /groups                    lists the groups with their statistics
+//	GET    /groups/{group}            shows one group
+//	DELETE /groups/{group}            purges the group from this node's caches
+//	GET    /groups/{group}/keys       lists the keys cached on this node, up to ?limit=
+//	GET    /groups/{group}/keys/{key} shows a cached key and its value without loading it
+//	DELETE /groups/{group}/keys/{key} removes a key from the whole cluster
+//	GET    /groups/{group}/snapshot   writes a snapshot of the group's main cache
+//	GET    /ring                      shows the members and, with ?points=true, the virtual nodes
+//	GET    /ring/owner/{key}          shows the node responsible for a key
+//
+// It is meant to be served apart from the peer protocol, on its own listener or behind
+// http.StripPrefix, so that it can be firewalled off.
+type AdminHandler struct {
+	ring     RingInspector // ring is the peer pool, or nil when the ring endpoints are not served.
+	registry *Registry     // registry holds the groups shown.
+	mux      *http.ServeMux
+}
+
+// defaultKeysLimit bounds the keys listed by the admin API when no limit is given.
+const defaultKeysLimit = 1000
+
+// registryServer is implemented by peer pools serving the groups of a registry.
+type registryServer interface {
+	Registry() *Registry
+}
+
+// NewAdminHandler creates the admin API of this node. ring, which may be nil, is the peer pool
+// whose membership and placement are shown. The groups shown are those the pool serves, or
+// those of DefaultRegistry without a pool.
+func NewAdminHandler(ring RingInspector) *AdminHandler {
+	h := &AdminHandler{ring: ring, registry: DefaultRegistry, mux: http.NewServeMux()}
+	if rs, ok := ring.(registryServer); ok {
+		h.registry = rs.Registry()
+	}
+	h.mux.HandleFunc("GET /groups", h.listGroups)
+	h.mux.HandleFunc("GET /groups/{group}", h.withGroup(h.showGroup))
+	h.mux.HandleFunc("DELETE /groups/{group}", h.withGroup(h.purgeGroup))
+	h.mux.HandleFunc("GET /groups/{group}/keys", h.withGroup(h.listKeys))
+	h.mux.HandleFunc("GET /groups/{group}/keys/{key...}", h.withGroup(h.showKey))
+	h.mux.HandleFunc("DELETE /groups/{group}/keys/{key...}", h.withGroup(h.removeKey))
+	h.mux.HandleFunc("GET /groups/{group}/snapshot", h.withGroup(h.snapshot))
+	h.mux.HandleFunc("GET /ring", h.showRing)
+	h.mux.HandleFunc("GET /ring/owner/{key...}", h.showOwner)
+	return h
+}
+
+// ServeHTTP routes an admin request.
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// withGroup resolves the group named in the path before calling fn.
+func (h *AdminHandler) withGroup(fn func(w http.ResponseWriter, r *http.Request, g *Group)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g := h.registry.Get(r.PathValue("group"))
+		if g == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no such group:%s", r.PathValue("group")))
+			return
+		}
+		fn(w, r, g)
+	}
+}
+
+// listGroups lists every group with its statistics.
+func (h *AdminHandler) listGroups(w http.ResponseWriter, r *http.Request) {
+	infos := []GroupInfo{}
+	for _, name := range h.registry.Names() {
+		if g := h.registry.Get(name); g != nil {
+			infos = append(infos, groupInfo(g))
+		}
+	}
+	writeJSON(w, infos)
+}
+
+// showGroup shows the statistics of a group.
+func (h *AdminHandler) showGroup(w http.ResponseWriter, r *http.Request, g *Group) {
+	writeJSON(w, groupInfo(g))
+}
+
+// purgeGroup drops every entry of a group from this node's caches.
+func (h *AdminHandler) purgeGroup(w http.ResponseWriter, r *http.Request, g *Group) {
+	g.Purge()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listKeys lists the live keys of a group's main and hot caches.
+func (h *AdminHandler) listKeys(w http.ResponseWriter, r *http.Request, g *Group) {
+	limit := defaultKeysLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+
+	keys := []KeyInfo{}
+	collect := func(which CacheType) func(key string, value ByteView) bool {
+		return func(key string, value ByteView) bool {
+			if !value.expired() {
+				keys = append(keys, newKeyInfo(key, which, value))
+			}
+			return len(keys) < limit
+		}
+	}
+	if limit > 0 && g.mainCache.walk(collect(MainCache)) {
+		g.hotCache.walk(collect(HotCache))
+	}
+	writeJSON(w, keys)
+}
+
+// showKey shows a key cached on this node, with its value.
+func (h *AdminHandler) showKey(w http.ResponseWriter, r *http.Request, g *Group) {
+	key := r.PathValue("key")
+	v, which, ok := g.Peek(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("key not cached:%s", key))
+		return
+	}
+	info := newKeyInfo(key, which, v)
+	info.Value = v.B
+	if h.ring != nil {
+		info.Owner, _ = h.ring.Owner(key)
+	}
+	writeJSON(w, info)
+}
+
+// removeKey removes a key from the whole cluster.
+func (h *AdminHandler) removeKey(w http.ResponseWriter, r *http.Request, g *Group) {
+	if err := g.Remove(r.Context(), r.PathValue("key")); err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// snapshot writes a snapshot of a group as the response body.
+func (h *AdminHandler) snapshot(w http.ResponseWriter, r *http.Request, g *Group) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := g.Snapshot(w); err != nil {
+		log.Printf("[Admin] Snapshot of group %s failed: %v", g.name, err)
+	}
+}
+
+// showRing shows the membership and placement of the peer pool.
+func (h *AdminHandler) showRing(w http.ResponseWriter, r *http.Request) {
+	if h.ring == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no peer pool"))
+		return
+	}
+	ring := h.ring.Ring()
+	if points, _ := strconv.ParseBool(r.URL.Query().Get("points")); !points {
+		ring.Points = nil
+	}
+	writeJSON(w, ring)
+}
+
+// showOwner shows the node responsible for a key.
+func (h *AdminHandler) showOwner(w http.ResponseWriter, r *http.Request) {
+	if h.ring == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no peer pool"))
+		return
+	}
+	key := r.PathValue("key")
+	owner, err := h.ring.Owner(key)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, map[string]string{"key": key, "owner": owner})
+}
+
+// groupInfo describes a group and its caches.
+func groupInfo(g *Group) GroupInfo {
+	caches := make(map[string]CacheStats, 3)
+	for _, which := range []CacheType{MainCache, HotCache, NegativeCache} {
+		caches[which.String()] = g.CacheStats(which)
+	}
+	return GroupInfo{Name: g.name, Stats: g.Stats(), Caches: caches}
+}
+
+// writeJSON writes v as the JSON body of a successful response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[Admin] Encoding response failed: %v", err)
+	}
+}
+
+// writeError writes err as the JSON body of a failed response.
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
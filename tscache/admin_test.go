@@ -0,0 +1,116 @@
+package tscache
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"tscache/consistenthash"
+)
+
+// adminGet requests path from the admin API and decodes the JSON response into v.
+func adminGet(t *testing.T, h http.Handler, path string, v interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("decoding %s: %v", path, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestAdminHandler(t *testing.T) {
+	group := NewGroup("admin-group", 1<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value-" + key), nil
+	}))
+	group.Get("a")
+	group.Get("b/c")
+
+	pool := NewHTTPPool("http://self")
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: "http://other", Weight: 2})
+	h := NewAdminHandler(pool)
+
+	var info GroupInfo
+	if code := adminGet(t, h, "/groups/admin-group", &info); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if info.Stats.Gets != 2 || info.Caches["main"].Items != 2 {
+		t.Errorf("unexpected group info %+v", info)
+	}
+	var infos []GroupInfo
+	adminGet(t, h, "/groups", &infos)
+	found := false
+	for _, info := range infos {
+		found = found || info.Name == "admin-group"
+	}
+	if !found {
+		t.Errorf("expected admin-group in %v", infos)
+	}
+	if code := adminGet(t, h, "/groups/no-such-group", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown group, got %d", code)
+	}
+
+	var key KeyInfo
+	if code := adminGet(t, h, "/groups/admin-group/keys/b/c", &key); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if key.Cache != "main" || string(key.Value) != "value-b/c" || key.Owner == "" {
+		t.Errorf("unexpected key info %+v", key)
+	}
+	if stats := group.Stats(); stats.Gets != 2 {
+		t.Errorf("expected inspecting a key not to count as a Get, got %d gets", stats.Gets)
+	}
+	if code := adminGet(t, h, "/groups/admin-group/keys/missing", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a key not cached, got %d", code)
+	}
+
+	var keys []KeyInfo
+	adminGet(t, h, "/groups/admin-group/keys?limit=1", &keys)
+	if len(keys) != 1 {
+		t.Errorf("expected 1 key, got %v", keys)
+	}
+
+	var ring Ring
+	adminGet(t, h, "/ring?points=true", &ring)
+	if len(ring.Nodes) != 2 || ring.Nodes[0].Name != "http://other" || ring.Nodes[0].VirtualNodes != 2*defaultReplicas {
+		t.Fatalf("unexpected ring %+v", ring.Nodes)
+	}
+	if share := ring.Nodes[0].Share + ring.Nodes[1].Share; share < 0.999 || share > 1.001 {
+		t.Errorf("expected the shares to cover the ring, got %v", share)
+	}
+	if len(ring.Points) != 3*defaultReplicas {
+		t.Errorf("expected %d points, got %d", 3*defaultReplicas, len(ring.Points))
+	}
+	var owner map[string]string
+	adminGet(t, h, "/ring/owner/a", &owner)
+	if expect, _ := pool.Owner("a"); owner["owner"] != expect {
+		t.Errorf("expected owner %s, got %v", expect, owner)
+	}
+
+	// Removing a key and purging the group
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/groups/admin-group/keys/a", nil))
+	if _, _, ok := group.Peek("a"); ok || rec.Code != http.StatusNoContent {
+		t.Errorf("expected the key to be removed, got status %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/groups/admin-group", nil))
+	if items := group.CacheStats(MainCache).Items; items != 0 || rec.Code != http.StatusNoContent {
+		t.Errorf("expected the group to be purged, got %d items and status %d", items, rec.Code)
+	}
+
+	// The snapshot endpoint writes a snapshot that can be restored
+	group.Get("d")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/groups/admin-group/snapshot", nil))
+	group.Purge()
+	if err := group.Restore(bytes.NewReader(rec.Body.Bytes())); err != nil {
+		t.Fatalf("restoring the snapshot: %v", err)
+	}
+	if _, _, ok := group.Peek("d"); !ok {
+		t.Error("expected the snapshot to hold d")
+	}
+}
@@ -0,0 +1,244 @@
+// Package arc implements a byte-bounded Adaptive Replacement Cache (Megiddo and Modha).
+//
+// Entries seen once live in the recency list t1 and entries seen more than once in the
+// frequency list t2. Ghost lists b1 and b2 remember the keys recently evicted from t1 and t2;
+// a hit in a ghost list moves the target size p of t1 towards the list that would have kept it.
+// All sizes, including p, are measured in bytes.
+package arc
+
+import (
+	"container/list"
+	"time"
+	"tscache/policy"
+)
+
+// Value is a cached value that reports its size in bytes.
+type Value = policy.Value
+
+// Cache is an ARC cache.
+type Cache struct {
+	maxBytes  int64                         // Maximum cache size in bytes; zero means unbounded
+	p         int64                         // Target size of t1 in bytes
+	t1, t2    *segment                      // Resident entries seen once and more than once
+	b1, b2    *segment                      // Ghost entries evicted from t1 and t2
+	cache     map[string]*list.Element      // Mapping of keys to elements of any of the four lists
+	OnEvicted func(key string, value Value) // Called for every resident entry dropped from the cache
+}
+
+// segment is one of the four ARC lists with its size in bytes.
+type segment struct {
+	ll     *list.List // Entries, most recently used at the front
+	nbytes int64      // Bytes accounted to the entries
+}
+
+// entry is a cached key/value pair. Ghost entries keep only the key and the size.
+type entry struct {
+	key    string
+	value  Value
+	size   int64     // len(key)+value.Len() at the time the entry was added
+	expire time.Time // Expiration time; zero means never
+	seg    *segment  // List holding the entry
+}
+
+// NewCache creates an ARC cache bounded to maxBytes.
+func NewCache(maxBytes int64, onEvicted func(key string, value Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		t1:        &segment{ll: list.New()},
+		t2:        &segment{ll: list.New()},
+		b1:        &segment{ll: list.New()},
+		b2:        &segment{ll: list.New()},
+		cache:     make(map[string]*list.Element),
+		OnEvicted: onEvicted,
+	}
+}
+
+// New creates an ARC cache as a policy.Store. It satisfies policy.Policy.
+func New(maxBytes int64, onEvicted func(key string, value Value)) policy.Store {
+	return NewCache(maxBytes, onEvicted)
+}
+
+// Get returns the value for key, promoting it to the frequency list.
+func (c *Cache) Get(key string) (Value, bool) {
+	elem, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if !c.resident(e) {
+		return nil, false
+	}
+	if !e.expire.IsZero() && !time.Now().Before(e.expire) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.move(elem, c.t2)
+	return e.value, true
+}
+
+// Peek returns the value for key without promoting it.
+func (c *Cache) Peek(key string) (Value, time.Time, bool) {
+	elem, ok := c.cache[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	e := elem.Value.(*entry)
+	if !c.resident(e) || (!e.expire.IsZero() && !time.Now().Before(e.expire)) {
+		return nil, time.Time{}, false
+	}
+	return e.value, e.expire, true
+}
+
+// Add adds or replaces the value for key; the entry never expires.
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire adds or replaces the value for key and adapts the target size on ghost hits.
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	size := int64(len(key)) + int64(value.Len())
+	elem, ok := c.cache[key]
+	if !ok {
+		e := &entry{key: key, value: value, size: size, expire: expire, seg: c.t1}
+		c.cache[key] = c.t1.ll.PushFront(e)
+		c.t1.nbytes += size
+		c.replace(false)
+		c.trimGhosts()
+		return
+	}
+
+	e := elem.Value.(*entry)
+	switch e.seg {
+	case c.t1, c.t2:
+		e.seg.nbytes += size - e.size
+	case c.b1:
+		c.p = min(c.maxBytes, c.p+max(size, size*c.b2.nbytes/max(c.b1.nbytes, 1)))
+		e.seg.nbytes -= e.size
+		e.seg.nbytes += size
+	case c.b2:
+		c.p = max(0, c.p-max(size, size*c.b1.nbytes/max(c.b2.nbytes, 1)))
+		e.seg.nbytes -= e.size
+		e.seg.nbytes += size
+	}
+	inB2 := e.seg == c.b2
+	e.value = value
+	e.size = size
+	e.expire = expire
+	c.move(elem, c.t2)
+	c.replace(inB2)
+	c.trimGhosts()
+}
+
+// replace evicts resident entries into the ghost lists until the resident bytes fit.
+func (c *Cache) replace(inB2 bool) {
+	if c.maxBytes == 0 {
+		return
+	}
+	for c.t1.nbytes+c.t2.nbytes > c.maxBytes {
+		from, to := c.t2, c.b2
+		if c.t1.ll.Len() > 0 && (c.t1.nbytes > c.p || (inB2 && c.t1.nbytes == c.p) || c.t2.ll.Len() == 0) {
+			from, to = c.t1, c.b1
+		}
+		elem := from.ll.Back()
+		e := elem.Value.(*entry)
+		c.move(elem, to)
+		value := e.value
+		e.value = nil
+		if c.OnEvicted != nil {
+			c.OnEvicted(e.key, value)
+		}
+	}
+}
+
+// trimGhosts bounds the ghost lists so that t1+b1 and the whole directory stay within budget.
+func (c *Cache) trimGhosts() {
+	if c.maxBytes == 0 {
+		return
+	}
+	for c.b1.ll.Len() > 0 && c.t1.nbytes+c.b1.nbytes > c.maxBytes {
+		c.dropGhost(c.b1.ll.Back())
+	}
+	for c.b2.ll.Len() > 0 && c.t1.nbytes+c.t2.nbytes+c.b1.nbytes+c.b2.nbytes > 2*c.maxBytes {
+		c.dropGhost(c.b2.ll.Back())
+	}
+}
+
+// move moves elem to the front of the segment to, keeping the byte accounting in sync.
+func (c *Cache) move(elem *list.Element, to *segment) {
+	e := elem.Value.(*entry)
+	if e.seg == to {
+		to.ll.MoveToFront(elem)
+		return
+	}
+	e.seg.ll.Remove(elem)
+	e.seg.nbytes -= e.size
+	e.seg = to
+	to.nbytes += e.size
+	c.cache[e.key] = to.ll.PushFront(e)
+}
+
+// dropGhost forgets a ghost entry.
+func (c *Cache) dropGhost(elem *list.Element) {
+	e := elem.Value.(*entry)
+	e.seg.ll.Remove(elem)
+	e.seg.nbytes -= e.size
+	delete(c.cache, e.key)
+}
+
+// resident reports whether e holds a value, as opposed to being a ghost.
+func (c *Cache) resident(e *entry) bool {
+	return e.seg == c.t1 || e.seg == c.t2
+}
+
+// Remove drops the entry for key, if present, without leaving a ghost.
+func (c *Cache) Remove(key string) {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// RemoveExpired drops every resident entry expired at now and returns how many were dropped.
+func (c *Cache) RemoveExpired(now time.Time) int {
+	removed := 0
+	for _, elem := range c.cache {
+		e := elem.Value.(*entry)
+		if c.resident(e) && !e.expire.IsZero() && !now.Before(e.expire) {
+			c.removeElement(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// removeElement forgets an entry and reports the eviction if it was resident.
+func (c *Cache) removeElement(elem *list.Element) {
+	e := elem.Value.(*entry)
+	resident := c.resident(e)
+	c.dropGhost(elem)
+	if resident && c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Walk calls fn for every resident entry, the recency list first and each list from its least
+// recently used entry, until fn returns false.
+func (c *Cache) Walk(fn func(key string, value Value, expire time.Time) bool) {
+	for _, seg := range []*segment{c.t1, c.t2} {
+		for elem := seg.ll.Back(); elem != nil; elem = elem.Prev() {
+			e := elem.Value.(*entry)
+			if !fn(e.key, e.value, e.expire) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of resident entries in the cache.
+func (c *Cache) Len() int {
+	return c.t1.ll.Len() + c.t2.ll.Len()
+}
+
+// Bytes returns the number of bytes held by resident keys and values in the cache.
+func (c *Cache) Bytes() int64 {
+	return c.t1.nbytes + c.t2.nbytes
+}
@@ -0,0 +1,98 @@
+package arc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	arc := NewCache(int64(0), nil)
+	arc.Add("key1", String("1234"))
+	if v, ok := arc.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := arc.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestEvictAndGhosts(t *testing.T) {
+	var evicted []string
+	arc := NewCache(int64(12), func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	arc.Add("k1", String("v1"))
+	arc.Add("k2", String("v2"))
+	arc.Add("k3", String("v3"))
+	arc.Get("k1")
+	arc.Add("k4", String("v4"))
+
+	if len(evicted) != 1 || evicted[0] != "k2" {
+		t.Fatalf("expect k2 to be evicted from the recency list, got %v", evicted)
+	}
+	if arc.Len() != 3 || arc.Bytes() != 12 {
+		t.Fatalf("expect 3 entries of 12 bytes, got len=%d bytes=%d", arc.Len(), arc.Bytes())
+	}
+	if _, ok := arc.Get("k2"); ok {
+		t.Fatalf("ghost k2 should miss")
+	}
+
+	// Re-adding a ghost of the recency list grows its target size.
+	arc.Add("k2", String("v2"))
+	if arc.p == 0 {
+		t.Fatalf("expect a b1 ghost hit to grow p")
+	}
+	if _, ok := arc.Get("k2"); !ok {
+		t.Fatalf("re-added k2 should hit")
+	}
+}
+
+func TestScanResistance(t *testing.T) {
+	arc := NewCache(int64(1000), nil)
+	hot := make([]string, 10)
+	for i := range hot {
+		hot[i] = fmt.Sprintf("hot%02d", i)
+		arc.Add(hot[i], String("vvvvv"))
+		arc.Get(hot[i])
+	}
+
+	// A scan of keys seen once must not flush the frequently used keys.
+	for i := 0; i < 1000; i++ {
+		arc.Add(fmt.Sprintf("scan%04d", i), String("vvvvv"))
+	}
+
+	for _, key := range hot {
+		if _, ok := arc.Get(key); !ok {
+			t.Fatalf("hot key %s was flushed by a scan", key)
+		}
+	}
+	if arc.Bytes() > 1000 {
+		t.Fatalf("budget exceeded: %d bytes", arc.Bytes())
+	}
+}
+
+func TestExpireAndRemove(t *testing.T) {
+	now := time.Now()
+	arc := NewCache(int64(0), nil)
+	arc.AddWithExpire("k1", String("v1"), now.Add(-time.Second))
+	arc.AddWithExpire("k2", String("v2"), now.Add(time.Hour))
+	arc.Add("k3", String("v3"))
+
+	if _, ok := arc.Get("k1"); ok {
+		t.Fatalf("expired k1 should miss")
+	}
+	if n := arc.RemoveExpired(now.Add(2 * time.Hour)); n != 1 {
+		t.Fatalf("expect 1 expired entry, got %d", n)
+	}
+	arc.Remove("k3")
+	if arc.Len() != 0 || arc.Bytes() != 0 {
+		t.Fatalf("expect an empty cache, got len=%d bytes=%d", arc.Len(), arc.Bytes())
+	}
+}
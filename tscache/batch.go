@@ -0,0 +1,356 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"tscache/compress"
+	pb "tscache/tscachepb"
+)
+
+// BatchGetter is implemented by getters that can load many keys in one call.
+// Keys missing from the returned map do not exist at the origin.
+type BatchGetter interface {
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+}
+
+// BatchGetterFunc is an adapter function that allows using ordinary functions as BatchGetter interfaces.
+type BatchGetterFunc func(ctx context.Context, keys []string) (map[string][]byte, error)
+
+// GetMany calls the BatchGetterFunc function itself.
+func (f BatchGetterFunc) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return f(ctx, keys)
+}
+
+// ExpiringValue is a value loaded by an ExpiringBatchGetter, with its own lifetime.
+type ExpiringValue struct {
+	B   []byte        // B is the value.
+	TTL time.Duration // TTL is how long the value stays valid; a non-positive TTL falls back to the group's default TTL.
+}
+
+// ExpiringBatchGetter is implemented by batch getters that decide how long each returned value
+// stays valid. Keys missing from the returned map do not exist at the origin.
+// Getters implementing ExpiringGetter load keys in batches only if they implement it too.
+type ExpiringBatchGetter interface {
+	GetManyWithTTL(ctx context.Context, keys []string) (map[string]ExpiringValue, error)
+}
+
+// BatchPeerGetter is implemented by peer getters that fetch many keys in one round trip.
+type BatchPeerGetter interface {
+	// GetMany fetches the values of the keys in the BatchRequest message from a peer.
+	// The BatchResponse message holds one Response per key, in request order.
+	GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error
+}
+
+// batchResult is the outcome of loading one key of a batch.
+type batchResult struct {
+	value ByteView
+	err   error
+}
+
+// batch collects the results of a GetMany call, which are set concurrently.
+type batch struct {
+	mu      sync.Mutex
+	results map[string]batchResult
+}
+
+// set records the result for a key.
+func (b *batch) set(key string, value ByteView, err error) {
+	b.mu.Lock()
+	b.results[key] = batchResult{value: value, err: err}
+	b.mu.Unlock()
+}
+
+// GetMany retrieves the values for many keys at once. Cached keys are served locally, the
+// remaining keys are grouped by owner and fetched with one request per peer, and the keys this
+// node owns are loaded with a single call when the getter implements BatchGetter or
+// ExpiringBatchGetter. Keys already being loaded by other callers are not loaded again.
+// Keys that do not exist are left out of the returned map. If some keys failed to load, the
+// values of the others are returned together with an error describing the failures.
+func (g *Group) GetMany(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	results := g.getMany(ctx, keys, true)
+	values := make(map[string]ByteView, len(results))
+	var errList []error
+	for key, r := range results {
+		switch {
+		case r.err == nil:
+			values[key] = r.value
+		case !errors.Is(r.err, ErrNotFound):
+			errList = append(errList, fmt.Errorf("%s: %w", key, r.err))
+		}
+	}
+	return values, errors.Join(errList...)
+}
+
+// getMany retrieves the values for many keys, returning the result of each distinct key.
+// Missing keys are fetched from the peers picked for them when route is set, as in get.
+func (g *Group) getMany(ctx context.Context, keys []string, route bool) map[string]batchResult {
+	b := &batch{results: make(map[string]batchResult, len(keys))}
+	if err := ctx.Err(); err != nil {
+		for _, key := range keys {
+			b.results[key] = batchResult{err: err}
+		}
+		return b.results
+	}
+
+	var misses []string
+	for _, key := range keys {
+		if _, ok := b.results[key]; ok {
+			continue
+		}
+		if key == "" {
+			b.results[key] = batchResult{err: fmt.Errorf("key is empty")}
+			continue
+		}
+		g.stats.gets.Add(1)
+		if v, err, ok := g.lookupCache(key); ok {
+			b.results[key] = batchResult{value: v, err: err}
+			continue
+		}
+		b.results[key] = batchResult{}
+		misses = append(misses, key)
+	}
+
+	byPeer := make(map[PeerGetter][]string)
+	var owned []string
+	for _, key := range misses {
+		if route && g.peers != nil {
+			if peer, ok := g.peers.PickPeer(key); ok {
+				byPeer[peer] = append(byPeer[peer], key)
+				continue
+			}
+		}
+		owned = append(owned, key)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		fallback []string
+	)
+	for peer, keys := range byPeer {
+		wg.Add(1)
+		go func(peer PeerGetter, keys []string) {
+			defer wg.Done()
+			failed := g.getManyFromPeer(ctx, peer, keys, b)
+			b.mu.Lock()
+			fallback = append(fallback, failed...)
+			b.mu.Unlock()
+		}(peer, keys)
+	}
+	wg.Wait()
+
+	g.getManyLocally(ctx, owned, fallback, b)
+	return b.results
+}
+
+// getManyFromPeer fetches keys owned by a peer in one request and records their results.
+// It returns the keys to load locally instead because the peer failed to serve them.
+// Peers that do not implement BatchPeerGetter are asked for each key separately.
+func (g *Group) getManyFromPeer(ctx context.Context, peer PeerGetter, keys []string, b *batch) (failed []string) {
+	bp, ok := peer.(BatchPeerGetter)
+	if !ok {
+		g.loadEach(ctx, keys, b)
+		return nil
+	}
+
+	request := &pb.BatchRequest{Group: g.name, Keys: keys, AcceptCompression: compress.Names(), Forwarded: true}
+	response := &pb.BatchResponse{}
+	err := bp.GetMany(ctx, request, response)
+	if errors.Is(err, errors.ErrUnsupported) {
+		g.loadEach(ctx, keys, b)
+		return nil
+	}
+	g.stats.loads.Add(int64(len(keys)))
+	g.stats.loadsDeduped.Add(int64(len(keys)))
+	if err == nil && len(response.GetValues()) != len(keys) {
+		err = fmt.Errorf("peer returned %d values for %d keys", len(response.GetValues()), len(keys))
+	}
+	if err != nil {
+		switch {
+		case ctx.Err() != nil:
+			g.stats.peerErrors.Add(int64(len(keys)))
+			for _, key := range keys {
+				b.set(key, ByteView{}, ctx.Err())
+			}
+			return nil
+		case errors.Is(err, ErrCircuitOpen):
+			g.stats.peerRejects.Add(int64(len(keys)))
+		default:
+			g.stats.peerErrors.Add(int64(len(keys)))
+			log.Println("[TSCache] Failed to get batch from peer", err)
+		}
+		return keys
+	}
+
+	for i, key := range keys {
+		value, err := g.fromResponse(key, response.GetValues()[i])
+		switch {
+		case err == nil:
+			g.stats.peerLoads.Add(1)
+			g.populateHotCache(key, value)
+			b.set(key, value, nil)
+		case errors.Is(err, ErrNotFound):
+			g.stats.peerLoads.Add(1)
+			b.set(key, ByteView{}, err)
+		default:
+			g.stats.peerErrors.Add(1)
+			log.Println("[TSCache] Failed to get from peer", err)
+			failed = append(failed, key)
+		}
+	}
+	return failed
+}
+
+// getManyLocally loads the keys this node owns and the keys its peers failed to serve, whose
+// loads are already counted, and records their results. A batch getter loads them in one call;
+// other getters load each key as Get would.
+func (g *Group) getManyLocally(ctx context.Context, owned, fallback []string, b *batch) {
+	getMany := g.batchGetter()
+	if getMany == nil {
+		var wg sync.WaitGroup
+		for _, key := range owned {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				value, err := g.load(ctx, key, false)
+				b.set(key, value, err)
+			}(key)
+		}
+		for _, key := range fallback {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				data, err := g.loads.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+					return g.loadLocally(ctx, key)
+				})
+				value, _ := data.(ByteView)
+				b.set(key, value, err)
+			}(key)
+		}
+		wg.Wait()
+		return
+	}
+
+	g.stats.loads.Add(int64(len(owned)))
+	keys := append(owned[:len(owned):len(owned)], fallback...)
+	if len(keys) == 0 {
+		return
+	}
+	f, calls := g.loads.claim(ctx, keys)
+	if f != nil {
+		var claimed []string
+		for i, key := range keys {
+			if calls[key].ctx != f {
+				continue
+			}
+			if i < len(owned) {
+				g.stats.loadsDeduped.Add(1)
+			}
+			claimed = append(claimed, key)
+		}
+		go g.loadBatch(f, getMany, claimed, calls)
+	}
+
+	for key, c := range calls {
+		select {
+		case <-c.done:
+			value, _ := c.val.(ByteView)
+			b.set(key, value, c.err)
+			continue
+		case <-ctx.Done():
+		}
+		if c.ctx != f {
+			g.loads.leave(c.ctx)
+		}
+		b.set(key, ByteView{}, ctx.Err())
+	}
+	if f != nil && ctx.Err() != nil {
+		g.loads.leave(f)
+	}
+}
+
+// batchGetter returns the function loading many keys in one call with the group's getter, or
+// nil if the getter has no batch support. A getter with per-key TTLs must also give them for
+// batches, so an ExpiringGetter is used only if it implements ExpiringBatchGetter.
+func (g *Group) batchGetter() func(ctx context.Context, keys []string) (map[string]ExpiringValue, error) {
+	switch getter := g.getter.(type) {
+	case ExpiringBatchGetter:
+		return getter.GetManyWithTTL
+	case ExpiringGetter:
+		return nil
+	case BatchGetter:
+		return func(ctx context.Context, keys []string) (map[string]ExpiringValue, error) {
+			found, err := getter.GetMany(ctx, keys)
+			if err != nil {
+				return nil, err
+			}
+			values := make(map[string]ExpiringValue, len(found))
+			for key, bytes := range found {
+				values[key] = ExpiringValue{B: bytes}
+			}
+			return values, nil
+		}
+	}
+	return nil
+}
+
+// loadBatch loads the keys of the calls claimed on flight f with one call to getMany, and
+// completes the calls.
+func (g *Group) loadBatch(f *flight, getMany func(ctx context.Context, keys []string) (map[string]ExpiringValue, error), keys []string, calls map[string]*call) {
+	if r, ok := g.peers.(selfLoadReporter); ok {
+		defer r.beginSelfLoad()()
+	}
+	found, err := getMany(f, keys)
+	f.stop(context.Canceled)
+	for _, key := range keys {
+		v, ok := found[key]
+		switch {
+		case err != nil:
+			g.stats.localLoadErrs.Add(1)
+			g.loads.finish(key, calls[key], ByteView{}, err)
+		case !ok:
+			g.stats.localLoadErrs.Add(1)
+			g.addNegative(key)
+			g.loads.finish(key, calls[key], ByteView{}, ErrNotFound)
+		default:
+			g.stats.localLoads.Add(1)
+			g.loads.finish(key, calls[key], g.addLoaded(key, v.B, v.TTL), nil)
+		}
+	}
+}
+
+// loadEach loads keys one at a time through load, concurrently, and records their results.
+func (g *Group) loadEach(ctx context.Context, keys []string, b *batch) {
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			value, err := g.load(ctx, key, true)
+			b.set(key, value, err)
+		}(key)
+	}
+	wg.Wait()
+}
+
+// batchResponse serves a BatchRequest from a peer, answering each key as Get would.
+func (g *Group) batchResponse(ctx context.Context, in *pb.BatchRequest) *pb.BatchResponse {
+	g.stats.serverRequests.Add(int64(len(in.GetKeys())))
+	results := g.getMany(ctx, in.GetKeys(), !in.GetForwarded())
+	response := &pb.BatchResponse{Values: make([]*pb.Response, len(in.GetKeys()))}
+	for i, key := range in.GetKeys() {
+		r := results[key]
+		switch {
+		case errors.Is(r.err, ErrNotFound):
+			response.Values[i] = &pb.Response{NotFound: true}
+		case r.err != nil:
+			response.Values[i] = &pb.Response{Error: r.err.Error()}
+		default:
+			response.Values[i] = newResponse(r.value, g.codec, in.GetAcceptCompression())
+		}
+	}
+	return response
+}
@@ -0,0 +1,305 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "tscache/tscachepb"
+)
+
+// batchOrigin is a getter that loads many keys at once from a map.
+type batchOrigin struct {
+	values  map[string]string
+	gate    chan struct{} // gate, if set, holds every load until it is closed.
+	gets    atomic.Int32
+	batches atomic.Int32
+}
+
+func (o *batchOrigin) Get(ctx context.Context, key string) ([]byte, error) {
+	o.gets.Add(1)
+	if o.gate != nil {
+		<-o.gate
+	}
+	if v, ok := o.values[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (o *batchOrigin) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	o.batches.Add(1)
+	if o.gate != nil {
+		<-o.gate
+	}
+	found := make(map[string][]byte)
+	for _, key := range keys {
+		if v, ok := o.values[key]; ok {
+			found[key] = []byte(v)
+		}
+	}
+	return found, nil
+}
+
+// peerPicker routes every key to a single peer.
+type peerPicker struct {
+	peer PeerGetter
+}
+
+func (p peerPicker) PickPeer(key string) (PeerGetter, bool) { return p.peer, true }
+func (p peerPicker) GetAll() []PeerGetter                   { return []PeerGetter{p.peer} }
+
+func TestGroup_GetMany(t *testing.T) {
+	origin := &batchOrigin{values: map[string]string{"a": "1", "b": "2"}}
+	group := NewGroupContext("batch-group", 1<<10, origin)
+
+	for i := 0; i < 2; i++ {
+		values, err := group.GetMany(context.Background(), []string{"a", "b", "missing", "a"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(values) != 2 || values["a"].String() != "1" || values["b"].String() != "2" {
+			t.Fatalf("unexpected values %v", values)
+		}
+	}
+	if n := origin.batches.Load(); n != 2 {
+		t.Errorf("expected the missing key to be loaded again in a second batch, got %d batches", n)
+	}
+	if n := origin.gets.Load(); n != 0 {
+		t.Errorf("expected no single-key loads, got %d", n)
+	}
+	if stats := group.Stats(); stats.CacheHits != 2 {
+		t.Errorf("expected 2 cache hits, got %d", stats.CacheHits)
+	}
+
+	// Getters without batch support load each key
+	var loads atomic.Int32
+	group = NewGroup("batch-single", 1<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads.Add(1)
+		if key == "bad" {
+			return nil, errors.New("origin down")
+		}
+		return []byte(key), nil
+	}))
+	values, err := group.GetMany(context.Background(), []string{"x", "y", "bad"})
+	if err == nil || len(values) != 2 || values["y"].String() != "y" {
+		t.Fatalf("unexpected result %v, %v", values, err)
+	}
+	if n := loads.Load(); n != 3 {
+		t.Errorf("expected 3 loads, got %d", n)
+	}
+
+	// Peers without batch support are asked for each key
+	owner := &fakePeer{values: map[string]string{"p": "1", "q": "2"}}
+	group = NewGroup("batch-peers", 1<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	group.RegisterNodes(&fakePicker{owner: owner})
+	if values, err := group.GetMany(context.Background(), []string{"p", "q"}); err != nil || len(values) != 2 {
+		t.Fatalf("unexpected result %v, %v", values, err)
+	}
+	if owner.gets != 2 {
+		t.Errorf("expected 2 peer fetches, got %d", owner.gets)
+	}
+}
+
+// TestGroup_GetManyDedup tests that batched keys share the loads in flight of Get, both ways,
+// and that each key is counted once.
+func TestGroup_GetManyDedup(t *testing.T) {
+	origin := &batchOrigin{values: map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}, gate: make(chan struct{})}
+	group := NewGroupContext("batch-dedup", 1<<10, origin)
+
+	batched := make(chan map[string]ByteView)
+	go func() {
+		values, _ := group.GetMany(context.Background(), []string{"a", "b"})
+		batched <- values
+	}()
+	waitCallers(t, group, "a", 1)
+	got := make(chan ByteView)
+	go func() {
+		view, _ := group.Get("a")
+		got <- view
+	}()
+	waitCallers(t, group, "a", 2)
+	close(origin.gate)
+	if values := <-batched; len(values) != 2 || values["a"].String() != "1" {
+		t.Fatalf("GetMany(a, b) = %v", values)
+	}
+	if view := <-got; view.String() != "1" {
+		t.Fatalf("Get(a) = %q, want 1", view.String())
+	}
+	if n := origin.gets.Load(); n != 0 {
+		t.Errorf("Get(a) during the batch loaded it again: %d single-key loads", n)
+	}
+
+	origin.gate = make(chan struct{})
+	go func() {
+		view, _ := group.Get("c")
+		got <- view
+	}()
+	waitCallers(t, group, "c", 1)
+	go func() {
+		values, _ := group.GetMany(context.Background(), []string{"c", "d"})
+		batched <- values
+	}()
+	waitCallers(t, group, "c", 2)
+	close(origin.gate)
+	if values := <-batched; len(values) != 2 || values["c"].String() != "3" || values["d"].String() != "4" {
+		t.Fatalf("GetMany(c, d) = %v", values)
+	}
+	<-got
+	if gets, batches := origin.gets.Load(), origin.batches.Load(); gets != 1 || batches != 2 {
+		t.Errorf("origin: %d single-key loads and %d batches, want 1 and 2", gets, batches)
+	}
+	if s := group.Stats(); s.Loads != 6 || s.LoadsDeduped != 4 || s.LocalLoads != 4 {
+		t.Errorf("loads = %d, deduped = %d, local = %d, want 6, 4 and 4", s.Loads, s.LoadsDeduped, s.LocalLoads)
+	}
+}
+
+// ttlBatchOrigin is a getter giving each key its own TTL, with or without batch support for it.
+type ttlBatchOrigin struct {
+	ttls    map[string]time.Duration
+	batches atomic.Int32
+}
+
+func (o *ttlBatchOrigin) Get(ctx context.Context, key string) ([]byte, error) {
+	return []byte(key), nil
+}
+
+func (o *ttlBatchOrigin) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	return []byte(key), o.ttls[key], nil
+}
+
+func (o *ttlBatchOrigin) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	o.batches.Add(1)
+	found := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		found[key] = []byte(key)
+	}
+	return found, nil
+}
+
+// expiringBatchOrigin also gives per-key TTLs for batches.
+type expiringBatchOrigin struct {
+	ttlBatchOrigin
+}
+
+func (o *expiringBatchOrigin) GetManyWithTTL(ctx context.Context, keys []string) (map[string]ExpiringValue, error) {
+	o.batches.Add(1)
+	found := make(map[string]ExpiringValue, len(keys))
+	for _, key := range keys {
+		found[key] = ExpiringValue{B: []byte(key), TTL: o.ttls[key]}
+	}
+	return found, nil
+}
+
+// TestGroup_GetManyTTL tests that batched keys keep the TTLs their getter gives them.
+func TestGroup_GetManyTTL(t *testing.T) {
+	ttls := map[string]time.Duration{"short": time.Minute}
+	expiring := &expiringBatchOrigin{ttlBatchOrigin{ttls: ttls}}
+	perKey := &ttlBatchOrigin{ttls: ttls}
+	for name, getter := range map[string]interface {
+		GetterCtx
+		ExpiringGetter
+	}{"batch-ttl-batched": expiring, "batch-ttl-per-key": perKey} {
+		group := NewGroupContext(name, 1<<10, getter, WithTTL(time.Hour))
+		start := time.Now()
+		values, err := group.GetMany(context.Background(), []string{"short", "default"})
+		if err != nil || len(values) != 2 {
+			t.Fatalf("%s: GetMany = %v, %v", name, values, err)
+		}
+		if e := values["short"].Expire(); e.Before(start) || e.After(start.Add(2*time.Minute)) {
+			t.Errorf("%s: short expires at %v, want about a minute from now", name, e)
+		}
+		if e := values["default"].Expire(); e.Before(start.Add(59 * time.Minute)) {
+			t.Errorf("%s: default expires at %v, want the group's hour", name, e)
+		}
+	}
+	if n := expiring.batches.Load(); n != 1 {
+		t.Errorf("ExpiringBatchGetter: %d batches, want 1", n)
+	}
+	if n := perKey.batches.Load(); n != 0 {
+		t.Errorf("ExpiringGetter without ExpiringBatchGetter: %d batches, want keys loaded one by one", n)
+	}
+}
+
+// downBatchPeer is a peer failing every batch.
+type downBatchPeer struct{}
+
+func (downBatchPeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	return errors.New("peer down")
+}
+
+func (downBatchPeer) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	return errors.New("peer down")
+}
+
+func (downBatchPeer) Remove(ctx context.Context, in *pb.Request) error { return nil }
+
+// TestGroup_GetManyFallback tests that keys loaded locally after their peer failed are counted once.
+func TestGroup_GetManyFallback(t *testing.T) {
+	origin := &batchOrigin{values: map[string]string{"a": "1", "b": "2"}}
+	single := &batchOrigin{values: origin.values}
+	for name, getter := range map[string]GetterCtx{
+		"batch-fallback":        origin,
+		"batch-fallback-single": GetterCtxFunc(single.Get),
+	} {
+		group := NewGroupContext(name, 1<<10, getter)
+		group.RegisterNodes(peerPicker{peer: downBatchPeer{}})
+		values, err := group.GetMany(context.Background(), []string{"a", "b"})
+		if err != nil || len(values) != 2 {
+			t.Fatalf("%s: GetMany = %v, %v", name, values, err)
+		}
+		if s := group.Stats(); s.Loads != 2 || s.LoadsDeduped != 2 || s.LocalLoads != 2 || s.PeerErrors != 2 {
+			t.Errorf("%s: loads = %d, deduped = %d, local = %d, peer errors = %d, want 2 each",
+				name, s.Loads, s.LoadsDeduped, s.LocalLoads, s.PeerErrors)
+		}
+	}
+	if n := origin.batches.Load(); n != 1 {
+		t.Errorf("expected the failed keys to be loaded in one batch, got %d", n)
+	}
+	if n := single.gets.Load(); n != 2 {
+		t.Errorf("expected 2 single-key loads, got %d", n)
+	}
+}
+
+func TestHTTPPool_GetMany(t *testing.T) {
+	origin := &batchOrigin{values: map[string]string{"a": "1", "b": "2", "c": "3"}}
+	owner := NewGroupContext("http-batch", 1<<10, origin)
+
+	var posts atomic.Int32
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts.Add(1)
+		}
+		pool.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	caller := &Group{name: "http-batch", mainCache: &cache{}}
+	caller.RegisterNodes(peerPicker{peer: &httpGetter{baseURL: server.URL + defaultBasePath}})
+	values, err := caller.GetMany(context.Background(), []string{"a", "b", "c", "missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 3 || values["c"].String() != "3" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if n := posts.Load(); n != 1 {
+		t.Errorf("expected a single batch request, got %d", n)
+	}
+	if n := origin.batches.Load(); n != 1 {
+		t.Errorf("expected the owner to load the keys in one batch, got %d", n)
+	}
+	if stats := owner.Stats(); stats.ServerRequests != 4 {
+		t.Errorf("expected 4 keys served to peers, got %d", stats.ServerRequests)
+	}
+	if stats := caller.Stats(); stats.PeerLoads != 4 {
+		t.Errorf("expected 4 peer loads, got %d", stats.PeerLoads)
+	}
+}
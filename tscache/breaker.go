@@ -0,0 +1,244 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+	pb "tscache/tscachepb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCircuitOpen is returned instead of calling a peer whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("tscache: circuit breaker is open")
+
+const (
+	defaultBreakerFailureRatio = 0.5
+	defaultBreakerMinRequests  = 5
+	defaultBreakerWindow       = 10 * time.Second
+	defaultBreakerCoolDown     = 5 * time.Second
+)
+
+// BreakerState is the state of a peer's circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the cool-down has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial call through to decide whether to close again.
+	BreakerHalfOpen
+)
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerOptions configures the circuit breakers of a peer pool.
+type BreakerOptions struct {
+	FailureRatio float64       // FailureRatio is the share of failed calls in a window that opens the breaker.
+	MinRequests  int           // MinRequests is the number of calls in a window before the ratio is considered.
+	Window       time.Duration // Window is the period over which calls are counted while closed.
+	CoolDown     time.Duration // CoolDown is how long the breaker stays open before a trial call.
+}
+
+// withDefaults fills unset options with their defaults.
+func (o BreakerOptions) withDefaults() BreakerOptions {
+	if o.FailureRatio <= 0 {
+		o.FailureRatio = defaultBreakerFailureRatio
+	}
+	if o.MinRequests <= 0 {
+		o.MinRequests = defaultBreakerMinRequests
+	}
+	if o.Window <= 0 {
+		o.Window = defaultBreakerWindow
+	}
+	if o.CoolDown <= 0 {
+		o.CoolDown = defaultBreakerCoolDown
+	}
+	return o
+}
+
+// circuitBreaker tracks the failures of calls to one peer.
+type circuitBreaker struct {
+	opts BreakerOptions
+
+	mu          sync.Mutex
+	state       BreakerState
+	requests    int       // requests counts calls in the current window.
+	failures    int       // failures counts failed calls in the current window.
+	windowStart time.Time // windowStart is when the current window began.
+	openedAt    time.Time // openedAt is when the breaker last opened.
+	trial       bool      // trial is set while the half-open trial call is in flight.
+}
+
+// newCircuitBreaker creates a closed circuit breaker.
+func newCircuitBreaker(opts BreakerOptions) *circuitBreaker {
+	return &circuitBreaker{opts: opts.withDefaults(), windowStart: time.Now()}
+}
+
+// allow reports whether a call may proceed, moving an open breaker to half-open after the cool-down.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.opts.CoolDown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return nil
+	case BreakerHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+		b.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// done records the outcome of an allowed call.
+func (b *circuitBreaker) done(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	switch b.state {
+	case BreakerHalfOpen:
+		b.trial = false
+		if ok {
+			b.state = BreakerClosed
+			b.requests, b.failures, b.windowStart = 0, 0, now
+		} else {
+			b.state, b.openedAt = BreakerOpen, now
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) > b.opts.Window {
+			b.requests, b.failures, b.windowStart = 0, 0, now
+		}
+		b.requests++
+		if !ok {
+			b.failures++
+		}
+		if b.requests >= b.opts.MinRequests && float64(b.failures)/float64(b.requests) >= b.opts.FailureRatio {
+			b.state, b.openedAt = BreakerOpen, now
+		}
+	}
+}
+
+// abandon releases the trial slot of a half-open breaker without recording an outcome, so that
+// the breaker stays half-open and lets the next call through as its trial.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.trial = false
+	}
+}
+
+// record records the outcome of an allowed call made with ctx. A call that failed because its
+// caller gave up tells nothing about the peer and is not recorded.
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		b.abandon()
+		return
+	}
+	b.done(!isPeerFailure(err))
+}
+
+// State returns the current state of the breaker.
+func (b *circuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.opts.CoolDown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// breakerGetter wraps a PeerGetter with a circuit breaker, failing fast while it is open.
+type breakerGetter struct {
+	peer    PeerGetter
+	breaker *circuitBreaker
+}
+
+// Get fetches from the wrapped peer unless the breaker is open.
+func (g *breakerGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	if err := g.breaker.allow(); err != nil {
+		return err
+	}
+	err := g.peer.Get(ctx, in, out)
+	g.breaker.record(ctx, err)
+	return err
+}
+
+// GetMany fetches a batch from the wrapped peer unless the breaker is open.
+// It returns errors.ErrUnsupported if the wrapped peer does not fetch batches.
+func (g *breakerGetter) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	bp, ok := g.peer.(BatchPeerGetter)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if err := g.breaker.allow(); err != nil {
+		return err
+	}
+	err := bp.GetMany(ctx, in, out)
+	g.breaker.record(ctx, err)
+	return err
+}
+
+// Remove asks the wrapped peer to drop a key unless the breaker is open.
+func (g *breakerGetter) Remove(ctx context.Context, in *pb.Request) error {
+	if err := g.breaker.allow(); err != nil {
+		return err
+	}
+	err := g.peer.Remove(ctx, in)
+	g.breaker.record(ctx, err)
+	return err
+}
+
+// isPeerFailure reports whether err means the peer is failing, as opposed to the peer answering
+// that it could not produce a value.
+func isPeerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= http.StatusInternalServerError
+	}
+	if s, ok := status.FromError(err); ok {
+		return s.Code() != codes.NotFound
+	}
+	return true
+}
+
+// breakerStater is implemented by peer pickers that guard their peers with circuit breakers.
+type breakerStater interface {
+	BreakerStates() map[string]BreakerState
+}
+
+// BreakerStates returns the circuit breaker state of every peer, keyed by peer name.
+// It returns nil when the group's peers are not guarded by circuit breakers.
+func (g *Group) BreakerStates() map[string]BreakerState {
+	if bs, ok := g.peers.(breakerStater); ok {
+		return bs.BreakerStates()
+	}
+	return nil
+}
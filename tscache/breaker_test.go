@@ -0,0 +1,199 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"tscache/consistenthash"
+	pb "tscache/tscachepb"
+)
+
+// flakyPeer fails every call while failing is set and counts the calls that reach it.
+type flakyPeer struct {
+	failing bool
+	calls   int
+}
+
+func (p *flakyPeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	p.calls++
+	if p.failing {
+		return errors.New("connection refused")
+	}
+	out.Value = []byte("remote")
+	return nil
+}
+
+func (p *flakyPeer) Remove(ctx context.Context, in *pb.Request) error {
+	return nil
+}
+
+// TestCircuitBreaker tests the closed, open and half-open transitions.
+func TestCircuitBreaker(t *testing.T) {
+	peer := &flakyPeer{failing: true}
+	getter := &breakerGetter{peer: peer, breaker: newCircuitBreaker(BreakerOptions{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		CoolDown:     20 * time.Millisecond,
+	})}
+	ctx := context.Background()
+	get := func() error {
+		return getter.Get(ctx, &pb.Request{Group: "g", Key: "k"}, &pb.Response{})
+	}
+
+	// Four failures open the breaker; later calls never reach the peer.
+	for i := 0; i < 4; i++ {
+		get()
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) || peer.calls != 4 {
+		t.Fatalf("expected ErrCircuitOpen without calling the peer, got %v after %d calls", err, peer.calls)
+	}
+	if s := getter.breaker.State(); s != BreakerOpen {
+		t.Fatalf("expected open, got %v", s)
+	}
+
+	// After the cool-down a failed trial opens the breaker again.
+	time.Sleep(25 * time.Millisecond)
+	if s := getter.breaker.State(); s != BreakerHalfOpen {
+		t.Fatalf("expected half-open, got %v", s)
+	}
+	if err := get(); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the trial call to reach the peer, got %v", err)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen after a failed trial, got %v", err)
+	}
+
+	// A successful trial closes it.
+	time.Sleep(25 * time.Millisecond)
+	peer.failing = false
+	if err := get(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := getter.breaker.State(); s != BreakerClosed {
+		t.Fatalf("expected closed, got %v", s)
+	}
+}
+
+// TestCircuitBreaker_AbandonedTrial tests that a trial call whose caller gave up neither closes
+// nor reopens a half-open breaker.
+func TestCircuitBreaker_AbandonedTrial(t *testing.T) {
+	peer := &flakyPeer{failing: true}
+	getter := &breakerGetter{peer: peer, breaker: newCircuitBreaker(BreakerOptions{
+		MinRequests: 1,
+		CoolDown:    20 * time.Millisecond,
+	})}
+	get := func(ctx context.Context) error {
+		return getter.Get(ctx, &pb.Request{Group: "g", Key: "k"}, &pb.Response{})
+	}
+
+	get(context.Background())
+	time.Sleep(25 * time.Millisecond)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := get(cancelled); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the trial call to reach the peer, got %v", err)
+	}
+	if s := getter.breaker.State(); s != BreakerHalfOpen {
+		t.Fatalf("expected half-open after an abandoned trial, got %v", s)
+	}
+
+	// The next call is the trial.
+	peer.failing = false
+	if err := get(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s := getter.breaker.State(); s != BreakerClosed {
+		t.Fatalf("expected closed, got %v", s)
+	}
+}
+
+// TestCircuitBreaker_IgnoresNotFound tests that a peer reporting a missing value does not trip the breaker.
+func TestCircuitBreaker_IgnoresNotFound(t *testing.T) {
+	b := newCircuitBreaker(BreakerOptions{MinRequests: 1})
+	for i := 0; i < 5; i++ {
+		b.allow()
+		b.done(!isPeerFailure(&statusError{code: 404, status: "404 Not Found"}))
+	}
+	if s := b.State(); s != BreakerClosed {
+		t.Fatalf("expected closed, got %v", s)
+	}
+}
+
+// TestGroup_BreakerStats tests that short-circuited peer fetches fall back to the getter and are counted.
+func TestGroup_BreakerStats(t *testing.T) {
+	group := NewGroup("breaker-group", 100, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}), WithHotCacheBytes(-1))
+	peer := &flakyPeer{failing: true}
+	group.RegisterNodes(&breakerPicker{getter: &breakerGetter{peer: peer, breaker: newCircuitBreaker(BreakerOptions{MinRequests: 2})}})
+
+	for i := 0; i < 4; i++ {
+		group.localRemove("key1")
+		if view, err := group.Get("key1"); err != nil || view.String() != "local" {
+			t.Fatalf("unexpected result %q, %v", view, err)
+		}
+	}
+	if s := group.Stats(); s.PeerErrors != 2 || s.PeerRejects != 2 || s.OpenBreakers != 1 || s.HalfOpenBreakers != 0 {
+		t.Errorf("expected 2 peer errors, 2 rejects and 1 open breaker, got %+v", s)
+	}
+	if states := group.BreakerStates(); states["peer"] != BreakerOpen {
+		t.Errorf("expected the peer's breaker to be open, got %v", states)
+	}
+
+	// Past its cool-down, an open breaker is counted as half-open
+	breaker := newCircuitBreaker(BreakerOptions{MinRequests: 1, CoolDown: time.Millisecond})
+	breaker.done(false)
+	group = NewGroup("breaker-half-open", 100, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("local"), nil
+	}))
+	group.RegisterNodes(&breakerPicker{getter: &breakerGetter{peer: peer, breaker: breaker}})
+	time.Sleep(5 * time.Millisecond)
+	if s := group.Stats(); s.OpenBreakers != 0 || s.HalfOpenBreakers != 1 {
+		t.Errorf("expected 1 half-open breaker, got %+v", s)
+	}
+}
+
+// breakerPicker routes every key to a single guarded peer.
+type breakerPicker struct {
+	getter *breakerGetter
+}
+
+func (p *breakerPicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.getter, true
+}
+
+func (p *breakerPicker) GetAll() []PeerGetter {
+	return []PeerGetter{p.getter}
+}
+
+func (p *breakerPicker) BreakerStates() map[string]BreakerState {
+	return map[string]BreakerState{"peer": p.getter.breaker.State()}
+}
+
+// TestHTTPPool_CircuitBreakers tests that a pool wraps its peers with breakers and reports their states.
+func TestHTTPPool_CircuitBreakers(t *testing.T) {
+	server := httptest.NewServer(NewHTTPPool("peer"))
+	addr := server.URL
+	server.Close()
+
+	pool := NewHTTPPool("http://self")
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: addr})
+	if pool.BreakerStates() != nil {
+		t.Fatal("expected no breaker states before enabling breakers")
+	}
+	pool.EnableCircuitBreakers(BreakerOptions{MinRequests: 2, CoolDown: time.Hour})
+
+	getter := pool.GetAll()[0]
+	if again := pool.GetAll()[0]; again != getter {
+		t.Fatal("expected a stable breaker per peer")
+	}
+	for i := 0; i < 3; i++ {
+		getter.Get(context.Background(), &pb.Request{Group: "g", Key: "k"}, &pb.Response{})
+	}
+	if states := pool.BreakerStates(); states[addr] != BreakerOpen {
+		t.Fatalf("expected the breaker of %s to be open, got %v", addr, states)
+	}
+}
@@ -1,8 +1,28 @@
 package tscache
 
+import "time"
+
 // ByteView represents an immutable view of bytes.
 type ByteView struct {
-	B []byte // B is the slice of bytes
+	B []byte    // B is the slice of bytes
+	e time.Time // e is the expiration time; zero means the view never expires
+	s time.Time // s is the time after which the view is stale and refreshed in the background; zero means never
+}
+
+// Expire returns the time after which the view is no longer valid.
+// A zero time means the view never expires.
+func (v ByteView) Expire() time.Time {
+	return v.e
+}
+
+// Stale reports whether the view is past its soft TTL and served while being refreshed.
+func (v ByteView) Stale() bool {
+	return !v.s.IsZero() && !time.Now().Before(v.s)
+}
+
+// expired reports whether the view has passed its expiration time.
+func (v ByteView) expired() bool {
+	return !v.e.IsZero() && !time.Now().Before(v.e)
 }
 
 // Len returns the length of the byte slice.
@@ -2,38 +2,265 @@ package tscache
 
 import (
 	"sync"
+	"time"
+	"tscache/compress"
 	"tscache/lru"
+	"tscache/policy"
 )
 
+// defaultSweepInterval is how often expired entries are reclaimed in the background.
+const defaultSweepInterval = time.Minute
+
+// cacher is implemented by the caches a Group can use for the keys it owns.
+type cacher interface {
+	add(key string, value ByteView)
+	get(key string) (ByteView, bool)
+	peek(key string) (ByteView, bool)
+	remove(key string)
+	purge()
+	close()
+	stats() CacheStats
+	walk(fn func(key string, value ByteView) bool) bool
+}
+
 // cache is a synchronized cache structure.
 type cache struct {
-	mu         sync.Mutex // Mutex for synchronization
-	lru        *lru.Cache // LRU cache instance
-	cacheBytes int64      // Maximum cache size in bytes
+	mu            sync.Mutex     // Mutex for synchronization
+	store         policy.Store   // Store holding the entries under the eviction policy
+	policy        policy.Policy  // Policy creating store; nil means LRU
+	cacheBytes    int64          // Maximum cache size in bytes
+	sweepInterval time.Duration  // Interval between background sweeps of expired entries
+	stopSweep     chan struct{}  // Closed to stop the sweeper; nil while no sweeper runs
+	closed        bool           // Set by close; no sweeper starts afterwards
+	codec         compress.Codec // Codec compressing stored values; nil stores them as is
+	nget          int64          // Number of lookups
+	nhit          int64          // Number of lookups that found a live entry
+	ndecodeErr    int64          // Number of lookups that found an entry failing to decode
+	nevict        int64          // Number of entries evicted to stay within cacheBytes
+	nremove       int64          // Number of entries removed explicitly
+	nexpire       int64          // Number of expired entries dropped
+	dropped       *int64         // Counter of the entries the store drops now; nil counts evictions
+}
+
+// compressedView is a value stored compressed by the cache's codec, so that the cache's byte
+// budget counts the compressed size.
+type compressedView struct {
+	b []byte    // b is the compressed value
+	e time.Time // e is the expiration time of the value
+	s time.Time // s is the time the value becomes stale
+}
+
+// Len returns the compressed length.
+func (v compressedView) Len() int {
+	return len(v.b)
 }
 
 // add adds a key-value pair to the cache.
-// It initializes the LRU cache if it's nil.
+// It initializes the store if it's nil.
+// Values with an expiration time are dropped lazily on get and reclaimed by a background sweeper.
+// With a codec, values are stored compressed unless compression does not make them smaller.
 func (c *cache) add(key string, value ByteView) {
+	var stored policy.Value = value
+	if c.codec != nil {
+		if b, err := c.codec.Encode(value.B); err == nil && len(b) < len(value.B) {
+			stored = compressedView{b: b, e: value.e, s: value.s}
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = lru.NewCache(c.cacheBytes, nil)
+	if c.store == nil {
+		newStore := c.policy
+		if newStore == nil {
+			newStore = lru.New
+		}
+		c.store = newStore(c.cacheBytes, c.onEvicted)
+	}
+	c.store.AddWithExpire(key, stored, value.e)
+	if !value.e.IsZero() && c.stopSweep == nil && !c.closed {
+		c.stopSweep = make(chan struct{})
+		go c.sweep(c.stopSweep)
 	}
-	c.lru.Add(key, value)
 }
 
 // get retrieves the value associated with the given key from the cache.
 // It returns the value and a boolean indicating whether the key was found.
 func (c *cache) get(key string) (ByteView, bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
+	c.nget++
+	if c.store == nil {
+		c.mu.Unlock()
 		return ByteView{}, false
 	}
+	// The store only drops the entry if it expired
+	c.dropped = &c.nexpire
+	ret, ok := c.store.Get(key)
+	c.dropped = nil
+	_, compressed := ret.(compressedView)
+	if ok && !compressed {
+		c.nhit++
+	}
+	c.mu.Unlock()
 
-	if ret, ok := c.lru.Get(key); ok {
+	if !ok {
+		return ByteView{}, false
+	}
+	if !compressed {
 		return ret.(ByteView), true
 	}
-	return ByteView{}, false
+	// Decompress outside the lock; the lookup is a hit only if the value decodes
+	view, ok := c.view(ret)
+	c.mu.Lock()
+	if ok {
+		c.nhit++
+	} else {
+		c.ndecodeErr++
+	}
+	c.mu.Unlock()
+	return view, ok
+}
+
+// peek retrieves the value for key without counting the lookup or recording the access.
+func (c *cache) peek(key string) (ByteView, bool) {
+	c.mu.Lock()
+	if c.store == nil {
+		c.mu.Unlock()
+		return ByteView{}, false
+	}
+	ret, _, ok := c.store.Peek(key)
+	c.mu.Unlock()
+
+	if !ok {
+		return ByteView{}, false
+	}
+	return c.view(ret)
+}
+
+// view returns the ByteView of a stored value, decompressing it if needed.
+func (c *cache) view(value policy.Value) (ByteView, bool) {
+	cv, ok := value.(compressedView)
+	if !ok {
+		return value.(ByteView), true
+	}
+	b, err := c.codec.Decode(cv.b)
+	if err != nil {
+		return ByteView{}, false
+	}
+	return ByteView{B: b, e: cv.e, s: cv.s}, true
+}
+
+// onEvicted counts entries dropped by the store, as evictions unless c.dropped tells why they
+// were dropped. It is called with c.mu held.
+func (c *cache) onEvicted(key string, value policy.Value) {
+	if c.dropped != nil {
+		*c.dropped++
+		return
+	}
+	c.nevict++
+}
+
+// stats returns the cache's statistics.
+func (c *cache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := CacheStats{
+		Gets:         c.nget,
+		Hits:         c.nhit,
+		DecodeErrors: c.ndecodeErr,
+		Evictions:    c.nevict,
+		Removals:     c.nremove,
+		Expirations:  c.nexpire,
+	}
+	if c.store != nil {
+		s.Bytes = c.store.Bytes()
+		s.Items = int64(c.store.Len())
+	}
+	return s
+}
+
+// remove deletes the entry for key from the cache, if present.
+func (c *cache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store == nil {
+		return
+	}
+	c.dropped = &c.nremove
+	c.store.Remove(key)
+	c.dropped = nil
+}
+
+// purge drops every entry and stops the sweeper until an expiring entry is added again.
+// Purged entries are not counted.
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = nil
+	c.stopSweeper()
+}
+
+// close drops every entry and stops the sweeper for good. Entries added afterwards are
+// only dropped lazily, when they are found expired.
+func (c *cache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store = nil
+	c.closed = true
+	c.stopSweeper()
+}
+
+// stopSweeper stops the sweeper, if it runs. It is called with c.mu held.
+func (c *cache) stopSweeper() {
+	if c.stopSweep != nil {
+		close(c.stopSweep)
+		c.stopSweep = nil
+	}
+}
+
+// walk calls fn for every entry, in the order of the store's Walk, until fn returns false.
+// It reports whether every entry was walked. fn is called with c.mu held.
+func (c *cache) walk(fn func(key string, value ByteView) bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store == nil {
+		return true
+	}
+	done := true
+	c.store.Walk(func(key string, value policy.Value, expire time.Time) bool {
+		if view, ok := c.view(value); ok && !fn(key, view) {
+			done = false
+		}
+		return done
+	})
+	return done
+}
+
+// removeExpired drops every expired entry so that their bytes no longer count against the budget.
+func (c *cache) removeExpired(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store == nil {
+		return 0
+	}
+	c.dropped = &c.nexpire
+	defer func() { c.dropped = nil }()
+	return c.store.RemoveExpired(now)
+}
+
+// sweep periodically removes expired entries until stop is closed.
+func (c *cache) sweep(stop <-chan struct{}) {
+	interval := c.sweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			c.removeExpired(now)
+		case <-stop:
+			return
+		}
+	}
 }
@@ -1,8 +1,20 @@
 package tscache
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+	"tscache/arc"
+	"tscache/compress"
+	"tscache/lfu"
+	"tscache/lru"
+	"tscache/policy"
+	"tscache/tinylfu"
 )
 
 func TestCache_AddAndGet(t *testing.T) {
@@ -61,3 +73,254 @@ func TestCache_ConcurrentAccess(t *testing.T) {
 	// Wait for goroutines to finish
 	wg.Wait()
 }
+
+func TestCache_Expire(t *testing.T) {
+	// Initialize cache
+	c := &cache{
+		cacheBytes: 1000, // Set maximum cache size
+	}
+
+	// Add an expired and a live item
+	c.add("key1", ByteView{B: []byte("value1"), e: time.Now().Add(-time.Second)})
+	c.add("key2", ByteView{B: []byte("value2"), e: time.Now().Add(time.Hour)})
+
+	// Verify the expired item is not returned
+	if _, found := c.get("key1"); found {
+		t.Errorf("Expected key1 to be expired")
+	}
+	if value, found := c.get("key2"); !found || value.String() != "value2" {
+		t.Errorf("Expected value2: value2, got: %s", value)
+	}
+}
+
+func TestCache_Sweep(t *testing.T) {
+	// Initialize cache with a short sweep interval
+	c := &cache{
+		cacheBytes:    1000,
+		sweepInterval: 10 * time.Millisecond,
+	}
+
+	// Add an item that expires almost immediately
+	c.add("key1", ByteView{B: []byte("value1"), e: time.Now().Add(20 * time.Millisecond)})
+
+	// Wait for the sweeper to reclaim the bytes without any get
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		nbytes := c.store.Bytes()
+		c.mu.Unlock()
+		if nbytes == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected sweeper to reclaim expired bytes")
+}
+
+// TestCache_StopSweeper tests that purge and close stop the sweeper, and that only purge lets
+// a later expiring entry start it again.
+func TestCache_StopSweeper(t *testing.T) {
+	c := &cache{cacheBytes: 1000, sweepInterval: 10 * time.Millisecond}
+	expiring := ByteView{B: []byte("value1"), e: time.Now().Add(time.Hour)}
+	stopped := func(stop chan struct{}) bool {
+		select {
+		case <-stop:
+			return true
+		default:
+			return false
+		}
+	}
+
+	c.add("key1", expiring)
+	stop := c.stopSweep
+	if stop == nil {
+		t.Fatal("Expected an expiring entry to start the sweeper")
+	}
+	c.purge()
+	if !stopped(stop) {
+		t.Error("Expected purge to stop the sweeper")
+	}
+
+	c.add("key1", expiring)
+	stop = c.stopSweep
+	if stop == nil {
+		t.Fatal("Expected the sweeper to start again after purge")
+	}
+	c.close()
+	if !stopped(stop) {
+		t.Error("Expected close to stop the sweeper")
+	}
+	if _, ok := c.get("key1"); ok {
+		t.Error("Expected close to drop the entries")
+	}
+
+	c.add("key1", expiring)
+	if c.stopSweep != nil {
+		t.Error("Expected no sweeper after close")
+	}
+}
+
+func TestCache_Policies(t *testing.T) {
+	policies := map[string]policy.Policy{
+		"lru":     lru.New,
+		"lfu":     lfu.New,
+		"arc":     arc.New,
+		"tinylfu": tinylfu.New,
+	}
+	for name, p := range policies {
+		t.Run(name, func(t *testing.T) {
+			// Initialize cache with the policy
+			c := &cache{cacheBytes: 1000, policy: p}
+
+			c.add("key1", ByteView{B: []byte("value1")})
+			if value, found := c.get("key1"); !found || value.String() != "value1" {
+				t.Errorf("Expected value1: value1, got: %s", value)
+			}
+
+			c.remove("key1")
+			if _, found := c.get("key1"); found {
+				t.Errorf("Expected key1 to be removed")
+			}
+			if s := c.stats(); s.Items != 0 || s.Bytes != 0 || s.Removals != 1 || s.Evictions != 0 {
+				t.Errorf("Unexpected stats %+v", s)
+			}
+		})
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	// Initialize a cache of 4 shards sharing an odd budget
+	budget := int64(4*minShardBytes + 1)
+	c := newShardedCache(4, budget, nil, 0, nil)
+
+	var total int64
+	for _, shard := range c.shards {
+		total += shard.cacheBytes
+	}
+	if len(c.shards) != 4 || total != budget {
+		t.Errorf("Expected 4 shards to share %d bytes, got %d sharing %d", budget, len(c.shards), total)
+	}
+
+	// Add items and retrieve them from their shards
+	for i := 0; i < 20; i++ {
+		c.add(fmt.Sprintf("key%d", i), ByteView{B: []byte("value")})
+	}
+	for i := 0; i < 20; i++ {
+		if value, found := c.get(fmt.Sprintf("key%d", i)); !found || value.String() != "value" {
+			t.Errorf("Expected value: value, got: %s", value)
+		}
+	}
+
+	c.remove("key0")
+	if _, found := c.get("key0"); found {
+		t.Errorf("Expected key0 to be removed")
+	}
+	if s := c.stats(); s.Items != 19 || s.Gets != 21 || s.Hits != 20 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	// A walk stops across shards once fn returns false
+	calls := 0
+	if c.walk(func(key string, value ByteView) bool {
+		calls++
+		return calls < 3
+	}) || calls != 3 {
+		t.Errorf("Expected a walk stopped after 3 entries, got %d calls", calls)
+	}
+}
+
+// benchmarkParallelGet measures concurrent cache hits over a fixed set of keys.
+func benchmarkParallelGet(b *testing.B, c cacher) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		c.add(keys[i], ByteView{B: []byte("value")})
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, &cache{cacheBytes: 1 << 20})
+}
+
+func BenchmarkShardedCache_ParallelGet(b *testing.B) {
+	benchmarkParallelGet(b, newShardedCache(16, 1<<20, nil, 0, nil))
+}
+
+// TestShardedCache_SmallBudget tests that a small budget gets fewer shards, none of them
+// unbounded or too small to hold entries.
+func TestShardedCache_SmallBudget(t *testing.T) {
+	c := newShardedCache(8, 3, nil, 0, nil)
+	if len(c.shards) != 1 || c.shards[0].cacheBytes != 3 {
+		t.Fatalf("Expected a single bounded shard for 3 bytes, got %d shards", len(c.shards))
+	}
+	c.add("key1", ByteView{B: []byte("value1")})
+	if s := c.stats(); s.Items != 0 {
+		t.Errorf("Expected an entry larger than the budget to be evicted, got %+v", s)
+	}
+
+	// Many shards over a small budget are reduced to shards that can hold entries
+	c = newShardedCache(64, 10<<10, nil, 0, nil)
+	if len(c.shards) != 2 {
+		t.Fatalf("Expected 2 shards for 10 KiB, got %d", len(c.shards))
+	}
+	for i := 0; i < 100; i++ {
+		c.add(fmt.Sprintf("key%d", i), ByteView{B: []byte("value")})
+	}
+	if s := c.stats(); s.Items != 100 || s.Evictions != 0 {
+		t.Errorf("Expected the small entries to fit, got %+v", s)
+	}
+}
+
+func TestCache_Compression(t *testing.T) {
+	c := &cache{cacheBytes: 1 << 20, codec: compress.LZ}
+	value := strings.Repeat(`{"name":"value","count":1},`, 40)
+	c.add("json", ByteView{B: []byte(value)})
+	c.add("short", ByteView{B: []byte("v")})
+
+	// The budget counts the compressed size
+	if s := c.stats(); s.Bytes >= int64(len(value))/4 {
+		t.Errorf("Expected compressed bytes well below %d, got %d", len(value), s.Bytes)
+	}
+	if got, ok := c.get("json"); !ok || got.String() != value {
+		t.Errorf("Expected the original value back, got %q", got)
+	}
+	if got, ok := c.get("short"); !ok || got.String() != "v" {
+		t.Errorf("Expected v, got %q", got)
+	}
+
+	var keys []string
+	c.walk(func(key string, value ByteView) bool {
+		keys = append(keys, key+"="+strconv.Itoa(value.Len()))
+		return true
+	})
+	if expect := []string{"json=" + strconv.Itoa(len(value)), "short=1"}; !reflect.DeepEqual(keys, expect) {
+		t.Errorf("Expected walk to return decompressed values %v, got %v", expect, keys)
+	}
+}
+
+// corruptCodec compresses every value to one byte that it cannot decode.
+type corruptCodec struct{}
+
+func (corruptCodec) Name() string                      { return "corrupt" }
+func (corruptCodec) Encode(src []byte) ([]byte, error) { return []byte{0}, nil }
+func (corruptCodec) Decode(src []byte) ([]byte, error) { return nil, errors.New("corrupt") }
+
+// TestCache_DecodeError tests that a value failing to decode is a miss, not a hit.
+func TestCache_DecodeError(t *testing.T) {
+	c := &cache{cacheBytes: 1 << 10, codec: corruptCodec{}}
+	c.add("key", ByteView{B: []byte("value")})
+	if _, ok := c.get("key"); ok {
+		t.Fatal("Expected a value failing to decode to be missed")
+	}
+	if s := c.stats(); s.Gets != 1 || s.Hits != 0 || s.DecodeErrors != 1 {
+		t.Errorf("Expected 1 get, no hit and 1 decode error, got %+v", s)
+	}
+}
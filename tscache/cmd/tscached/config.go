@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration file of tscached. It is YAML; JSON, being a subset of YAML,
+// works as well. Durations are written like "10s" and sizes like "64MB".
+type Config struct {
+	Listen          string        `yaml:"listen"`           // Listen is the address serving the peer protocol.
+	Self            string        `yaml:"self"`             // Self is the name of this node among the peers; defaults to http://<listen>, which needs a host.
+	API             string        `yaml:"api"`              // API is the address serving clients, if any.
+	Admin           string        `yaml:"admin"`            // Admin is the address serving the admin API, if any.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // ShutdownTimeout bounds the draining of requests on shutdown.
+
+	Peers   PeersConfig    `yaml:"peers"`
+	Health  *HealthConfig  `yaml:"health"`  // Health enables health checks of the peers.
+	Breaker *BreakerConfig `yaml:"breaker"` // Breaker enables circuit breakers on the peers.
+	Groups  []GroupConfig  `yaml:"groups"`
+}
+
+// PeersConfig lists the peers statically or tells where to discover them.
+// Exactly one source may be set; without one, the node is alone.
+type PeersConfig struct {
+	Static   []string      `yaml:"static"`   // Static lists the peer addresses.
+	File     string        `yaml:"file"`     // File is a peers file, polled for changes.
+	Env      string        `yaml:"env"`      // Env is an environment variable listing the peers.
+	DNS      *DNSConfig    `yaml:"dns"`      // DNS looks the peers up in SRV records, polled for changes.
+	Interval time.Duration `yaml:"interval"` // Interval is the polling interval of discovered peers.
+}
+
+// DNSConfig configures the discovery of peers from SRV records.
+type DNSConfig struct {
+	Service string `yaml:"service"`
+	Proto   string `yaml:"proto"`
+	Name    string `yaml:"name"`
+	Scheme  string `yaml:"scheme"`
+}
+
+// HealthConfig configures the health checks of the peers.
+type HealthConfig struct {
+	Interval         time.Duration `yaml:"interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+}
+
+// BreakerConfig configures the circuit breakers of the peers.
+type BreakerConfig struct {
+	FailureRatio float64       `yaml:"failure_ratio"`
+	MinRequests  int           `yaml:"min_requests"`
+	Window       time.Duration `yaml:"window"`
+	CoolDown     time.Duration `yaml:"cool_down"`
+}
+
+// GroupConfig configures a cache group.
+type GroupConfig struct {
+	Name        string        `yaml:"name"`
+	Size        ByteSize      `yaml:"size"`        // Size is the cache budget of the group.
+	TTL         time.Duration `yaml:"ttl"`         // TTL is the lifetime of loaded values; zero keeps them until evicted.
+	Policy      string        `yaml:"policy"`      // Policy is lru (the default), lfu, arc or tinylfu.
+	Shards      int           `yaml:"shards"`      // Shards splits the main cache into independently locked shards.
+	Compression string        `yaml:"compression"` // Compression names the codec of cached values, if any.
+
+	Negative *NegativeConfig `yaml:"negative"` // Negative enables caching of missing keys.
+	Stale    *StaleConfig    `yaml:"stale"`    // Stale enables stale-while-revalidate.
+	Snapshot *SnapshotConfig `yaml:"snapshot"` // Snapshot restores the group on start and saves it on shutdown.
+
+	Origin OriginConfig `yaml:"origin"`
+}
+
+// NegativeConfig configures the negative cache of a group.
+type NegativeConfig struct {
+	TTL  time.Duration `yaml:"ttl"`
+	Size ByteSize      `yaml:"size"`
+}
+
+// StaleConfig configures stale-while-revalidate for a group.
+type StaleConfig struct {
+	SoftTTL      time.Duration `yaml:"soft_ttl"`
+	MaxRefreshes int           `yaml:"max_refreshes"`
+}
+
+// SnapshotConfig configures the snapshots of a group.
+type SnapshotConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"` // Interval between periodic snapshots; zero saves on shutdown only.
+}
+
+// OriginConfig selects the origin a group loads missing keys from.
+type OriginConfig struct {
+	Type    string            `yaml:"type"`    // Type is http, file or static.
+	URL     string            `yaml:"url"`     // URL is the http origin; "{key}" is replaced by the escaped key, which is appended otherwise.
+	Timeout time.Duration     `yaml:"timeout"` // Timeout bounds a request to the http origin.
+	Headers map[string]string `yaml:"headers"` // Headers are sent with every request to the http origin.
+	Dir     string            `yaml:"dir"`     // Dir is the directory of the file origin, holding one file per key.
+	Values  map[string]string `yaml:"values"`  // Values are the keys and values of the static origin.
+}
+
+// defaultShutdownTimeout bounds the draining of requests on shutdown when none is configured.
+const defaultShutdownTimeout = 10 * time.Second
+
+// loadConfig reads and validates the configuration file at path.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parseConfig parses and validates a configuration, filling in defaults.
+func parseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Listen == "" {
+		return nil, errors.New("listen is not set")
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	sources := 0
+	for _, set := range []bool{len(cfg.Peers.Static) > 0, cfg.Peers.File != "", cfg.Peers.Env != "", cfg.Peers.DNS != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, errors.New("peers: set only one of static, file, env and dns")
+	}
+
+	if len(cfg.Groups) == 0 {
+		return nil, errors.New("no groups")
+	}
+	names := make(map[string]bool, len(cfg.Groups))
+	for i, g := range cfg.Groups {
+		if g.Name == "" {
+			return nil, fmt.Errorf("groups[%d]: name is not set", i)
+		}
+		if names[g.Name] {
+			return nil, fmt.Errorf("group %s: defined twice", g.Name)
+		}
+		names[g.Name] = true
+		if g.Size <= 0 {
+			return nil, fmt.Errorf("group %s: size is not set", g.Name)
+		}
+	}
+
+	// A wildcard listen address is no name the peers know this node by
+	if host, _, err := net.SplitHostPort(cfg.Listen); cfg.Self == "" && (err != nil || host == "" || net.ParseIP(host).IsUnspecified()) {
+		return nil, fmt.Errorf("self is not set, and listen %q does not name this node", cfg.Listen)
+	}
+	return cfg, nil
+}
+
+// ByteSize is a size in bytes, written as a number of bytes or with a unit such as "64MB" or "1GiB".
+// Decimal and binary units both count in powers of 1024.
+type ByteSize int64
+
+// byteUnits maps size suffixes to their multipliers, longest suffixes first.
+var byteUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// UnmarshalYAML parses a size.
+func (s *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	v := strings.ToUpper(strings.TrimSpace(value.Value))
+	mult := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(v, unit.suffix) {
+			v, mult = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix)), unit.n
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value.Value)
+	}
+	*s = ByteSize(n * mult)
+	return nil
+}
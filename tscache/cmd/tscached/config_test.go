@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+listen: ":8001"
+self: http://localhost:8001
+peers:
+  static: [http://localhost:8001, http://localhost:8002]
+groups:
+  - name: scores
+    size: 64MB
+    ttl: 10m
+    negative: {ttl: 10s, size: 1KiB}
+    origin: {type: static, values: {a: "1"}}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g := cfg.Groups[0]
+	if g.Size != 64<<20 || g.TTL != 10*time.Minute || g.Negative.Size != 1<<10 || g.Origin.Values["a"] != "1" {
+		t.Errorf("unexpected group %+v", g)
+	}
+	if cfg.ShutdownTimeout != defaultShutdownTimeout || len(cfg.Peers.Static) != 2 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	// JSON is accepted too
+	cfg, err = parseConfig([]byte(`{"listen": ":8001", "self": "http://localhost:8001", "groups": [{"name": "g", "size": 1024, "ttl": "1s", "origin": {"type": "static"}}]}`))
+	if err != nil || cfg.Groups[0].Size != 1024 || cfg.Groups[0].TTL != time.Second {
+		t.Errorf("unexpected result %+v, %v", cfg, err)
+	}
+
+	for _, tc := range []struct{ config, err string }{
+		{`groups: [{name: g, size: 1}]`, "listen is not set"},
+		{`listen: ":1"`, "no groups"},
+		{`{listen: ":1", groups: [{name: g}]}`, "size is not set"},
+		{`{listen: ":1", groups: [{name: g, size: 1}, {name: g, size: 1}]}`, "defined twice"},
+		{`{listen: ":1", groups: [{name: g, size: 12XB}]}`, "invalid size"},
+		{`{listen: ":1", peers: {static: [a], file: f}, groups: [{name: g, size: 1}]}`, "only one"},
+		{`{listen: ":1", groups: [{name: g, size: 1}]}`, "self is not set"},
+		{`{listen: "0.0.0.0:1", groups: [{name: g, size: 1}]}`, "self is not set"},
+		{`{listen: "[::]:1", groups: [{name: g, size: 1}]}`, "self is not set"},
+	} {
+		if _, err := parseConfig([]byte(tc.config)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.config, tc.err, err)
+		}
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	cfg, err := loadConfig("tscached.example.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, gc := range cfg.Groups {
+		if _, err := newOrigin(gc.Origin); err != nil {
+			t.Errorf("group %s: %v", gc.Name, err)
+		}
+	}
+	if _, err := loadConfig(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+}
@@ -0,0 +1,313 @@
+// Command tscached runs a tscache node configured by a YAML or JSON file.
+//
+// A node serves the peer protocol and, optionally, a client API and the admin API:
+//
+//	GET /api/{group}/{key}  returns the value of a key, loading it from the origin if needed
+//
+// On SIGINT or SIGTERM, the node stops accepting requests, lets those in flight finish within
+// the shutdown timeout, and writes the snapshots of the groups that have one.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tscache"
+	"tscache/arc"
+	"tscache/compress"
+	"tscache/consistenthash"
+	"tscache/discovery"
+	"tscache/lfu"
+	"tscache/lru"
+	"tscache/policy"
+	"tscache/tinylfu"
+)
+
+func main() {
+	configPath := flag.String("config", "tscached.yaml", "configuration file, YAML or JSON")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d, err := newDaemon(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := d.serve(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// policies maps policy names to eviction policies.
+var policies = map[string]policy.Policy{
+	"lru":     lru.New,
+	"lfu":     lfu.New,
+	"arc":     arc.New,
+	"tinylfu": tinylfu.New,
+}
+
+// daemon is a running tscache node.
+type daemon struct {
+	cfg    *Config
+	self   string            // self is the name of this node among the peers.
+	pool   *tscache.HTTPPool // pool serves the peer protocol.
+	groups []*tscache.Group
+	snaps  map[*tscache.Group]*SnapshotConfig // snaps holds the snapshot configuration of each group with one.
+
+	listeners map[string]net.Listener // listeners maps server names to their listeners.
+	servers   map[string]*http.Server // servers maps server names to their servers.
+}
+
+// newDaemon creates the groups of the configuration and listens on its addresses.
+func newDaemon(cfg *Config) (_ *daemon, err error) {
+	d := &daemon{
+		cfg:       cfg,
+		snaps:     make(map[*tscache.Group]*SnapshotConfig),
+		listeners: make(map[string]net.Listener),
+		servers:   make(map[string]*http.Server),
+	}
+	defer func() {
+		if err != nil {
+			d.close()
+		}
+	}()
+
+	registry := tscache.NewRegistry()
+	for _, gc := range cfg.Groups {
+		g, err := newGroup(registry, gc)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", gc.Name, err)
+		}
+		d.groups = append(d.groups, g)
+		if gc.Snapshot != nil {
+			d.snaps[g] = gc.Snapshot
+		}
+	}
+
+	if err := d.listen("peer", cfg.Listen); err != nil {
+		return nil, err
+	}
+	d.self = cfg.Self
+	if d.self == "" {
+		// Name the node as configured, as peer lists do, unless the port is only known once listening
+		d.self = "http://" + cfg.Listen
+		if _, port, _ := net.SplitHostPort(cfg.Listen); port == "0" {
+			d.self = "http://" + d.listeners["peer"].Addr().String()
+		}
+	}
+	d.pool = tscache.NewHTTPPool(d.self, tscache.WithRegistry(registry))
+	peerMux := http.NewServeMux()
+	peerMux.Handle(d.pool.BasePath(), d.pool)
+	d.servers["peer"] = &http.Server{Handler: peerMux}
+	for _, g := range d.groups {
+		g.RegisterNodes(d.pool)
+	}
+
+	if cfg.API != "" {
+		if err := d.listen("api", cfg.API); err != nil {
+			return nil, err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/{group}/{key...}", d.serveAPI)
+		d.servers["api"] = &http.Server{Handler: mux}
+	}
+	if cfg.Admin != "" {
+		if err := d.listen("admin", cfg.Admin); err != nil {
+			return nil, err
+		}
+		d.servers["admin"] = &http.Server{Handler: tscache.NewAdminHandler(d.pool)}
+	}
+	return d, nil
+}
+
+// newGroup creates a group of the registry from its configuration.
+func newGroup(registry *tscache.Registry, gc GroupConfig) (*tscache.Group, error) {
+	getter, err := newOrigin(gc.Origin)
+	if err != nil {
+		return nil, err
+	}
+	var opts []tscache.GroupOption
+	if gc.TTL > 0 {
+		opts = append(opts, tscache.WithTTL(gc.TTL))
+	}
+	if gc.Policy != "" {
+		p, ok := policies[gc.Policy]
+		if !ok {
+			return nil, fmt.Errorf("unknown policy %q", gc.Policy)
+		}
+		opts = append(opts, tscache.WithPolicy(p))
+	}
+	if gc.Shards > 1 {
+		opts = append(opts, tscache.WithShards(gc.Shards))
+	}
+	if gc.Compression != "" {
+		codec, ok := compress.Lookup(gc.Compression)
+		if !ok {
+			return nil, fmt.Errorf("unknown compression %q", gc.Compression)
+		}
+		opts = append(opts, tscache.WithCompression(codec))
+	}
+	if gc.Negative != nil {
+		opts = append(opts, tscache.WithNegativeCache(gc.Negative.TTL, int64(gc.Negative.Size)))
+	}
+	if gc.Stale != nil {
+		opts = append(opts, tscache.WithStaleWhileRevalidate(gc.Stale.SoftTTL, gc.Stale.MaxRefreshes))
+	}
+	return registry.NewGroupContext(gc.Name, int64(gc.Size), getter, opts...), nil
+}
+
+// listen listens on addr for the named server.
+func (d *daemon) listen(name, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("%s server: %w", name, err)
+	}
+	d.listeners[name] = ln
+	return nil
+}
+
+// serve runs the node until ctx is done or a server fails, then shuts it down.
+func (d *daemon) serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for g, sc := range d.snaps {
+		if err := g.RestoreFile(sc.Path); err != nil {
+			log.Printf("[tscached] Restoring group %s from %s failed, starting cold: %v", g.Name(), sc.Path, err)
+		}
+		if sc.Interval > 0 {
+			g.StartSnapshots(ctx, sc.Path, sc.Interval)
+		}
+	}
+	if err := d.startPeers(ctx); err != nil {
+		d.close()
+		return err
+	}
+
+	errc := make(chan error, len(d.servers))
+	for name, srv := range d.servers {
+		log.Printf("[tscached] Serving %s on %s", name, d.listeners[name].Addr())
+		go func(name string, srv *http.Server) {
+			if err := srv.Serve(d.listeners[name]); !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("%s server: %w", name, err)
+			}
+		}(name, srv)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Printf("[tscached] Shutting down")
+	case err = <-errc:
+	}
+	return errors.Join(err, d.shutdown())
+}
+
+// startPeers sets the peers of the node from the configuration, and keeps following
+// discovered peers until ctx is done.
+func (d *daemon) startPeers(ctx context.Context) error {
+	pc := d.cfg.Peers
+	var discoverer discovery.Discoverer
+	switch {
+	case pc.File != "":
+		discoverer = &discovery.File{Path: pc.File}
+	case pc.Env != "":
+		discoverer = &discovery.Env{Var: pc.Env}
+	case pc.DNS != nil:
+		discoverer = &discovery.SRV{Service: pc.DNS.Service, Proto: pc.DNS.Proto, Name: pc.DNS.Name, Scheme: pc.DNS.Scheme}
+	default:
+		nodes := []*consistenthash.Node{{Name: d.self}}
+		for _, name := range pc.Static {
+			if name != d.self {
+				nodes = append(nodes, &consistenthash.Node{Name: name})
+			}
+		}
+		d.pool.Set(nodes...)
+	}
+	if discoverer != nil {
+		if err := discovery.Start(ctx, discoverer, d.pool, pc.Interval); err != nil {
+			return fmt.Errorf("discovering peers: %w", err)
+		}
+	}
+
+	if hc := d.cfg.Health; hc != nil {
+		d.pool.StartHealthChecks(ctx, tscache.HealthOptions{
+			Interval:         hc.Interval,
+			Timeout:          hc.Timeout,
+			FailureThreshold: hc.FailureThreshold,
+		})
+	}
+	if bc := d.cfg.Breaker; bc != nil {
+		d.pool.EnableCircuitBreakers(tscache.BreakerOptions{
+			FailureRatio: bc.FailureRatio,
+			MinRequests:  bc.MinRequests,
+			Window:       bc.Window,
+			CoolDown:     bc.CoolDown,
+		})
+	}
+	return nil
+}
+
+// shutdown stops the servers, letting requests in flight finish within the shutdown timeout,
+// then writes the snapshots.
+func (d *daemon) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errList []error
+	for name, srv := range d.servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errList = append(errList, fmt.Errorf("%s server: %w", name, err))
+		}
+	}
+	for g, sc := range d.snaps {
+		if err := g.SnapshotFile(sc.Path); err != nil {
+			errList = append(errList, fmt.Errorf("snapshot of group %s: %w", g.Name(), err))
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// close closes the listeners of a daemon that does not serve.
+func (d *daemon) close() {
+	for _, ln := range d.listeners {
+		ln.Close()
+	}
+}
+
+// serveAPI serves the value of a key to clients.
+func (d *daemon) serveAPI(w http.ResponseWriter, r *http.Request) {
+	g := d.pool.Registry().Get(r.PathValue("group"))
+	if g == nil {
+		http.Error(w, "no such group:"+r.PathValue("group"), http.StatusNotFound)
+		return
+	}
+	view, err := g.GetContext(r.Context(), r.PathValue("key"))
+	switch {
+	case errors.Is(err, tscache.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	default:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if expire := view.Expire(); !expire.IsZero() {
+			w.Header().Set("Expires", expire.UTC().Format(http.TimeFormat))
+		}
+		w.Write(view.ByteSlice())
+	}
+}
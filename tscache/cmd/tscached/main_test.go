@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tscache"
+)
+
+func TestDaemon(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "daemon.snap")
+	cfg, err := parseConfig([]byte(`
+listen: 127.0.0.1:0
+api: 127.0.0.1:0
+admin: 127.0.0.1:0
+groups:
+  - name: daemon-group
+    size: 1KB
+    snapshot: {path: ` + snapshot + `}
+    origin: {type: static, values: {key1: value1}}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d, err := newDaemon(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.serve(ctx) }()
+
+	// Without keep-alives, no idle or spare connection delays the server's shutdown
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	api := "http://" + d.listeners["api"].Addr().String()
+	for _, tc := range []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/daemon-group/key1", http.StatusOK, "value1"},
+		{"/api/daemon-group/missing", http.StatusNotFound, ""},
+		{"/api/no-such-group/key1", http.StatusNotFound, ""},
+	} {
+		res, err := client.Get(api + tc.path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tc.code || (tc.body != "" && string(body) != tc.body) {
+			t.Errorf("%s: unexpected response %d %q", tc.path, res.StatusCode, body)
+		}
+	}
+	// The peer listener serves the pool under its base path only
+	peer := "http://" + d.listeners["peer"].Addr().String()
+	if d.self != peer {
+		t.Errorf("self = %s, want the address listened on port 0, %s", d.self, peer)
+	}
+	for path, code := range map[string]int{
+		"/":                            http.StatusNotFound,
+		"/_tscache/daemon-group/key1":  http.StatusOK,
+		"/_tscache/no-such-group/key1": http.StatusNotFound,
+	} {
+		res, err := client.Get(peer + path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != code {
+			t.Errorf("peer %s: unexpected status %d, want %d", path, res.StatusCode, code)
+		}
+	}
+	res, err := client.Get("http://" + d.listeners["admin"].Addr().String() + "/ring")
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected admin response %v, %v", res, err)
+	}
+	res.Body.Close()
+
+	// Shutting down writes the snapshot
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not shut down")
+	}
+	restored := tscache.NewGroup("daemon-restored", 1<<10, tscache.GetterFunc(func(key string) ([]byte, error) {
+		return nil, tscache.ErrNotFound
+	}))
+	if err := restored.RestoreFile(snapshot); err == nil {
+		t.Error("expected the snapshot to belong to daemon-group")
+	}
+	if err := d.pool.Registry().Get("daemon-group").RestoreFile(snapshot); err != nil {
+		t.Errorf("unexpected error restoring the snapshot: %v", err)
+	}
+}
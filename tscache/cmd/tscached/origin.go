@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tscache"
+)
+
+// defaultOriginTimeout bounds a request to an http origin when no timeout is configured.
+const defaultOriginTimeout = 5 * time.Second
+
+// newOrigin creates the getter of a group from its origin configuration.
+func newOrigin(cfg OriginConfig) (tscache.GetterCtx, error) {
+	switch cfg.Type {
+	case "http":
+		if cfg.URL == "" {
+			return nil, errors.New("http origin: url is not set")
+		}
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = defaultOriginTimeout
+		}
+		return &httpOrigin{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: timeout}}, nil
+	case "file":
+		if cfg.Dir == "" {
+			return nil, errors.New("file origin: dir is not set")
+		}
+		return fileOrigin(cfg.Dir), nil
+	case "static":
+		return staticOrigin(cfg.Values), nil
+	case "":
+		return nil, errors.New("origin type is not set")
+	default:
+		return nil, fmt.Errorf("unknown origin type %q", cfg.Type)
+	}
+}
+
+// httpOrigin loads values with GET requests. A 404 answer means the key does not exist, and
+// the max-age of a Cache-Control header sets the TTL of the value.
+type httpOrigin struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+var _ tscache.ExpiringGetter = (*httpOrigin)(nil)
+
+// Get loads the value for key.
+func (o *httpOrigin) Get(ctx context.Context, key string) ([]byte, error) {
+	b, _, err := o.GetWithTTL(ctx, key)
+	return b, err
+}
+
+// GetWithTTL loads the value for key and the TTL given by the origin.
+func (o *httpOrigin) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	u := o.url
+	if strings.Contains(u, "{key}") {
+		u = strings.ReplaceAll(u, "{key}", url.PathEscape(key))
+	} else {
+		u += url.PathEscape(key)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for name, value := range o.headers {
+		req.Header.Set(name, value)
+	}
+	res, err := o.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, 0, fmt.Errorf("%s: %w", key, tscache.ErrNotFound)
+	case res.StatusCode != http.StatusOK:
+		return nil, 0, fmt.Errorf("origin returned:%v", res.Status)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading origin response: %v", err)
+	}
+	return b, maxAge(res.Header.Get("Cache-Control")), nil
+}
+
+// maxAge returns the max-age directive of a Cache-Control header, or zero.
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+// fileOrigin loads values from the files of a directory, one file per key.
+type fileOrigin string
+
+// Get reads the file named by key. Keys that would leave the directory do not exist.
+func (o fileOrigin) Get(ctx context.Context, key string) ([]byte, error) {
+	if !filepath.IsLocal(key) {
+		return nil, fmt.Errorf("%s: %w", key, tscache.ErrNotFound)
+	}
+	b, err := os.ReadFile(filepath.Join(string(o), key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, tscache.ErrNotFound)
+	}
+	return b, err
+}
+
+// staticOrigin serves values from the configuration, for development and tests.
+type staticOrigin map[string]string
+
+// Get returns the configured value for key.
+func (o staticOrigin) Get(ctx context.Context, key string) ([]byte, error) {
+	if v, ok := o[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("%s: %w", key, tscache.ErrNotFound)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tscache"
+)
+
+func TestHTTPOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/items/a%2Fb" || r.Header.Get("Authorization") != "token" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Write([]byte("value"))
+	}))
+	defer server.Close()
+
+	getter, err := newOrigin(OriginConfig{Type: "http", URL: server.URL + "/items/{key}", Headers: map[string]string{"Authorization": "token"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, ttl, err := getter.(tscache.ExpiringGetter).GetWithTTL(context.Background(), "a/b")
+	if err != nil || string(b) != "value" || ttl != time.Minute {
+		t.Errorf("unexpected result %q, %v, %v", b, ttl, err)
+	}
+	if _, err := getter.Get(context.Background(), "missing"); !errors.Is(err, tscache.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFileOrigin(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "key"), []byte("value"), 0o644)
+	getter, _ := newOrigin(OriginConfig{Type: "file", Dir: dir})
+
+	if b, err := getter.Get(context.Background(), "key"); err != nil || string(b) != "value" {
+		t.Errorf("unexpected result %q, %v", b, err)
+	}
+	for _, key := range []string{"missing", "../key", "/etc/passwd"} {
+		if _, err := getter.Get(context.Background(), key); !errors.Is(err, tscache.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tscache"
+	"tscache/compress"
+	pb "tscache/tscachepb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// value is the result of get.
+type value struct {
+	Group  string     `json:"group"`
+	Key    string     `json:"key"`
+	Value  []byte     `json:"value"`
+	Expire *time.Time `json:"expire,omitempty"`
+	Peer   string     `json:"peer"` // Peer is the node that answered.
+}
+
+// get fetches a value from the first peer that answers. The peer routes the request to the owner.
+func get(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	group, key := args[0], args[1]
+	var errList []error
+	for _, peer := range cfg.peers {
+		v, err := fetch(ctx, cfg, peer, group, key)
+		if err == nil {
+			return printValue(w, cfg, v)
+		}
+		var se *statusError
+		if errors.As(err, &se) || errors.Is(err, tscache.ErrNotFound) {
+			return err
+		}
+		errList = append(errList, fmt.Errorf("%s: %w", peer, err))
+	}
+	return errors.Join(errList...)
+}
+
+// fetch fetches a value from one peer with the peer protocol.
+func fetch(ctx context.Context, cfg *config, peer, group, key string) (*value, error) {
+	u := peer + cfg.basePath + url.QueryEscape(group) + "/" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(tscache.AcceptCompressionHeader, strings.Join(compress.Names(), ","))
+	body, err := send(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.Response{}
+	if err := proto.Unmarshal(body, response); err != nil {
+		return nil, fmt.Errorf("decoding response: %v", err)
+	}
+	if response.GetNotFound() {
+		return nil, fmt.Errorf("%s: %w", key, tscache.ErrNotFound)
+	}
+	v := &value{Group: group, Key: key, Value: response.GetValue(), Peer: peer}
+	if name := response.GetCompression(); name != "" {
+		codec, ok := compress.Lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("response uses unknown compression %q", name)
+		}
+		if v.Value, err = codec.Decode(v.Value); err != nil {
+			return nil, fmt.Errorf("decoding %s response: %w", name, err)
+		}
+	}
+	if expire := response.GetExpire(); expire != 0 {
+		t := time.Unix(0, expire)
+		v.Expire = &t
+	}
+	return v, nil
+}
+
+// printValue prints the result of get.
+func printValue(w io.Writer, cfg *config, v *value) error {
+	if cfg.json {
+		return writeJSON(w, v)
+	}
+	t := newTable(w, "KEY", "VALUE", "SIZE", "EXPIRE", "PEER")
+	t.row(v.Key, printable(v.Value), len(v.Value), formatExpire(v.Expire), v.Peer)
+	return t.flush()
+}
+
+// result is the outcome of a command on one node.
+type result struct {
+	Node  string `json:"node"`
+	Error string `json:"error,omitempty"`
+}
+
+// remove drops a key from every peer.
+func remove(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	u := cfg.basePath + url.QueryEscape(args[0]) + "/" + url.QueryEscape(args[1])
+	return onEach(ctx, cfg, w, cfg.peers, http.MethodDelete, u)
+}
+
+// purge drops every entry of a group from every node.
+func purge(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	return onEach(ctx, cfg, w, cfg.admins, http.MethodDelete, "/groups/"+url.PathEscape(args[0]))
+}
+
+// onEach sends a request without a response body to every node and prints the outcomes.
+func onEach(ctx context.Context, cfg *config, w io.Writer, nodes []string, method, path string) error {
+	results := make([]result, 0, len(nodes))
+	failed := 0
+	for _, node := range nodes {
+		r := result{Node: node}
+		req, err := http.NewRequestWithContext(ctx, method, node+path, nil)
+		if err == nil {
+			_, err = send(req)
+		}
+		if err != nil {
+			r.Error = err.Error()
+			failed++
+		}
+		results = append(results, r)
+	}
+
+	if cfg.json {
+		if err := writeJSON(w, results); err != nil {
+			return err
+		}
+	} else {
+		t := newTable(w, "NODE", "RESULT")
+		for _, r := range results {
+			t.row(r.Node, orDefault(r.Error, "ok"))
+		}
+		if err := t.flush(); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed on %d of %d nodes", failed, len(nodes))
+	}
+	return nil
+}
+
+// nodeGroups are the groups of one node.
+type nodeGroups struct {
+	Node   string              `json:"node"`
+	Groups []tscache.GroupInfo `json:"groups"`
+}
+
+// stats shows the statistics of every node, for one group or all of them.
+func stats(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	var all []nodeGroups
+	for _, admin := range cfg.admins {
+		ng := nodeGroups{Node: admin}
+		if len(args) == 1 {
+			var info tscache.GroupInfo
+			if err := getJSON(ctx, admin+"/groups/"+url.PathEscape(args[0]), &info); err != nil {
+				return fmt.Errorf("%s: %w", admin, err)
+			}
+			ng.Groups = []tscache.GroupInfo{info}
+		} else if err := getJSON(ctx, admin+"/groups", &ng.Groups); err != nil {
+			return fmt.Errorf("%s: %w", admin, err)
+		}
+		all = append(all, ng)
+	}
+
+	if cfg.json {
+		return writeJSON(w, all)
+	}
+	t := newTable(w, "NODE", "GROUP", "GETS", "HITS", "LOADS", "PEER LOADS", "LOCAL LOADS", "ERRORS", "ITEMS", "BYTES")
+	for _, ng := range all {
+		for _, g := range ng.Groups {
+			s, main := g.Stats, g.Caches["main"]
+			t.row(ng.Node, g.Name, s.Gets, s.CacheHits, s.Loads, s.PeerLoads, s.LocalLoads,
+				s.PeerErrors+s.LocalLoadErrs, main.Items, main.Bytes)
+		}
+	}
+	return t.flush()
+}
+
+// ring shows the members of the ring as seen by the first admin endpoint that answers.
+func ring(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	var r tscache.Ring
+	if err := firstJSON(ctx, cfg, "/ring", &r); err != nil {
+		return err
+	}
+	if cfg.json {
+		return writeJSON(w, r)
+	}
+	t := newTable(w, "NODE", "WEIGHT", "HEALTHY", "VIRTUAL NODES", "SHARE")
+	for _, n := range r.Nodes {
+		name := n.Name
+		if name == r.Self {
+			name += " (self)"
+		}
+		t.row(name, n.Weight, n.Healthy, n.VirtualNodes, fmt.Sprintf("%.1f%%", n.Share*100))
+	}
+	return t.flush()
+}
+
+// owner shows the node responsible for a key.
+func owner(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	var o struct {
+		Key   string `json:"key"`
+		Owner string `json:"owner"`
+	}
+	if err := firstJSON(ctx, cfg, "/ring/owner/"+url.PathEscape(args[0]), &o); err != nil {
+		return err
+	}
+	if cfg.json {
+		return writeJSON(w, o)
+	}
+	t := newTable(w, "KEY", "OWNER")
+	t.row(o.Key, o.Owner)
+	return t.flush()
+}
+
+// nodeKeys are the keys cached on one node.
+type nodeKeys struct {
+	Node string            `json:"node"`
+	Keys []tscache.KeyInfo `json:"keys"`
+}
+
+// dump lists the keys of a group cached on every node.
+func dump(ctx context.Context, cfg *config, args []string, w io.Writer) error {
+	var all []nodeKeys
+	for _, admin := range cfg.admins {
+		nk := nodeKeys{Node: admin}
+		if err := getJSON(ctx, admin+"/groups/"+url.PathEscape(args[0])+"/keys", &nk.Keys); err != nil {
+			return fmt.Errorf("%s: %w", admin, err)
+		}
+		all = append(all, nk)
+	}
+
+	if cfg.json {
+		return writeJSON(w, all)
+	}
+	t := newTable(w, "NODE", "KEY", "CACHE", "SIZE", "EXPIRE", "STALE")
+	for _, nk := range all {
+		for _, k := range nk.Keys {
+			t.row(nk.Node, k.Key, k.Cache, k.Size, formatExpire(k.Expire), k.Stale)
+		}
+	}
+	return t.flush()
+}
+
+// firstJSON decodes the response to path from the first admin endpoint that answers.
+func firstJSON(ctx context.Context, cfg *config, path string, v interface{}) error {
+	var errList []error
+	for _, admin := range cfg.admins {
+		err := getJSON(ctx, admin+path, v)
+		if err == nil {
+			return nil
+		}
+		var se *statusError
+		if errors.As(err, &se) {
+			return err
+		}
+		errList = append(errList, fmt.Errorf("%s: %w", admin, err))
+	}
+	return errors.Join(errList...)
+}
+
+// getJSON decodes the JSON response to a GET request.
+func getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	body, err := send(req)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("decoding response: %v", err)
+	}
+	return nil
+}
+
+// statusError is returned when a node answers with an unsuccessful status.
+type statusError struct {
+	status string // status is the HTTP status line.
+	msg    string // msg is the error reported in the body, if any.
+}
+
+// Error returns the status and the error reported by the node.
+func (e *statusError) Error() string {
+	if e.msg == "" {
+		return e.status
+	}
+	return e.status + ": " + e.msg
+}
+
+// send sends a request and returns the body of a successful response.
+func send(req *http.Request) ([]byte, error) {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %v", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		se := &statusError{status: res.Status}
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &e) == nil {
+			se.msg = e.Error
+		} else {
+			se.msg = strings.TrimSpace(string(body))
+		}
+		return nil, se
+	}
+	return body, nil
+}
+
+// printable returns b as text if it is valid UTF-8, and quoted otherwise.
+func printable(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return strconv.Quote(string(b))
+}
+
+// formatExpire formats an expiration time; nil means never.
+func formatExpire(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// orDefault returns s, or def if s is empty.
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
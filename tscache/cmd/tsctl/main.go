@@ -0,0 +1,158 @@
+// Command tsctl operates a tscache cluster over its peer protocol and admin API.
+//
+// Usage:
+//
+//	tsctl [flags] get <group> <key>     fetch a value through a peer, which routes it to its owner
+//	tsctl [flags] remove <group> <key>  drop a key from every peer
+//	tsctl [flags] stats [group]         show the statistics of every node
+//	tsctl [flags] ring                  show the members of the ring
+//	tsctl [flags] owner <key>           show the node responsible for a key
+//	tsctl [flags] purge <group>         drop every entry of a group from every node
+//	tsctl [flags] dump <group>          list the keys cached on every node
+//
+// get and remove use the peers given with -peers or -peers-file; the other commands use the
+// admin endpoints given with -admin or -admin-file. Both files use the format of discovery.File.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"tscache/discovery"
+)
+
+// usage is printed for invalid command lines.
+const usage = `usage: tsctl [flags] <command> [args]
+
+commands:
+  get <group> <key>     fetch a value through a peer
+  remove <group> <key>  drop a key from every peer
+  stats [group]         show the statistics of every node
+  ring                  show the members of the ring
+  owner <key>           show the node responsible for a key
+  purge <group>         drop every entry of a group from every node
+  dump <group>          list the keys cached on every node
+
+flags:
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "tsctl:", err)
+		os.Exit(1)
+	}
+}
+
+// config holds the parsed command line.
+type config struct {
+	peers    []string      // peers are the base URLs of the peer protocol.
+	admins   []string      // admins are the base URLs of the admin API.
+	basePath string        // basePath is the path of the peer protocol.
+	json     bool          // json selects JSON output instead of tables.
+	timeout  time.Duration // timeout bounds the whole command.
+}
+
+// run executes the command line args, writing results to stdout.
+func run(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("tsctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	var (
+		cfg        config
+		peers      = flags.String("peers", "", "comma-separated base URLs of the peers, e.g. http://localhost:8001")
+		peersFile  = flags.String("peers-file", "", "file listing the peers")
+		admins     = flags.String("admin", "", "comma-separated base URLs of the admin endpoints, e.g. http://localhost:9001")
+		adminsFile = flags.String("admin-file", "", "file listing the admin endpoints")
+		output     = flags.String("o", "table", "output format: table or json")
+	)
+	flags.StringVar(&cfg.basePath, "base-path", "/_tscache/", "path of the peer protocol")
+	flags.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "timeout of the command")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	switch *output {
+	case "table":
+	case "json":
+		cfg.json = true
+	default:
+		return fmt.Errorf("unknown output format %q", *output)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+	var err error
+	if cfg.peers, err = addresses(ctx, *peers, *peersFile); err != nil {
+		return err
+	}
+	if cfg.admins, err = addresses(ctx, *admins, *adminsFile); err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no command")
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		flags.Usage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if len(args)-1 < cmd.minArgs || len(args)-1 > cmd.maxArgs {
+		return fmt.Errorf("usage: tsctl %s %s", args[0], cmd.args)
+	}
+	if cmd.needsPeers && len(cfg.peers) == 0 {
+		return fmt.Errorf("%s needs -peers or -peers-file", args[0])
+	}
+	if !cmd.needsPeers && len(cfg.admins) == 0 {
+		return fmt.Errorf("%s needs -admin or -admin-file", args[0])
+	}
+	return cmd.run(ctx, &cfg, args[1:], stdout)
+}
+
+// command is a tsctl command.
+type command struct {
+	args       string // args describes the arguments of the command.
+	minArgs    int
+	maxArgs    int
+	needsPeers bool // needsPeers is set for commands using the peer protocol instead of the admin API.
+	run        func(ctx context.Context, cfg *config, args []string, w io.Writer) error
+}
+
+// commands maps command names to commands.
+var commands = map[string]command{
+	"get":    {args: "<group> <key>", minArgs: 2, maxArgs: 2, needsPeers: true, run: get},
+	"remove": {args: "<group> <key>", minArgs: 2, maxArgs: 2, needsPeers: true, run: remove},
+	"stats":  {args: "[group]", minArgs: 0, maxArgs: 1, run: stats},
+	"ring":   {minArgs: 0, maxArgs: 0, run: ring},
+	"owner":  {args: "<key>", minArgs: 1, maxArgs: 1, run: owner},
+	"purge":  {args: "<group>", minArgs: 1, maxArgs: 1, run: purge},
+	"dump":   {args: "<group>", minArgs: 1, maxArgs: 1, run: dump},
+}
+
+// addresses returns the base URLs given as a comma-separated list, followed by those in path.
+func addresses(ctx context.Context, list, path string) ([]string, error) {
+	var addrs []string
+	for _, addr := range strings.Split(list, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, strings.TrimSuffix(addr, "/"))
+		}
+	}
+	if path != "" {
+		nodes, err := (&discovery.File{Path: path}).Discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			addrs = append(addrs, strings.TrimSuffix(node.Name, "/"))
+		}
+	}
+	return addrs, nil
+}
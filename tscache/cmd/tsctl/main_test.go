@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tscache"
+	"tscache/consistenthash"
+)
+
+// startNode serves a group over the peer protocol and the admin API.
+func startNode(t *testing.T) (peer, admin string) {
+	t.Helper()
+	tscache.NewGroup("tsctl-group", 1<<10, tscache.GetterFunc(func(key string) ([]byte, error) {
+		if key == "missing" {
+			return nil, tscache.ErrNotFound
+		}
+		return []byte("value-" + key), nil
+	}))
+
+	peerServer := httptest.NewUnstartedServer(nil)
+	pool := tscache.NewHTTPPool("http://" + peerServer.Listener.Addr().String())
+	pool.Set(&consistenthash.Node{Name: "http://" + peerServer.Listener.Addr().String()})
+	peerServer.Config.Handler = pool
+	peerServer.Start()
+	adminServer := httptest.NewServer(tscache.NewAdminHandler(pool))
+	t.Cleanup(func() {
+		peerServer.Close()
+		adminServer.Close()
+	})
+	return peerServer.URL, adminServer.URL
+}
+
+func TestRun(t *testing.T) {
+	peer, admin := startNode(t)
+	tsctl := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		err := run(append([]string{"-peers", peer, "-admin", admin}, args...), &stdout, &stderr)
+		return stdout.String(), err
+	}
+
+	out, err := tsctl("get", "tsctl-group", "key1")
+	if err != nil || !strings.Contains(out, "value-key1") {
+		t.Fatalf("unexpected get output %q, %v", out, err)
+	}
+	if _, err := tsctl("get", "tsctl-group", "missing"); !errors.Is(err, tscache.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	out, err = tsctl("-o", "json", "dump", "tsctl-group")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var dumped []nodeKeys
+	if err := json.Unmarshal([]byte(out), &dumped); err != nil || len(dumped) != 1 || len(dumped[0].Keys) != 1 {
+		t.Fatalf("unexpected dump output %q, %v", out, err)
+	}
+
+	out, err = tsctl("stats", "tsctl-group")
+	if err != nil || !strings.Contains(out, "tsctl-group") {
+		t.Errorf("unexpected stats output %q, %v", out, err)
+	}
+	out, err = tsctl("ring")
+	if err != nil || !strings.Contains(out, "(self)") || !strings.Contains(out, "100.0%") {
+		t.Errorf("unexpected ring output %q, %v", out, err)
+	}
+	out, err = tsctl("owner", "key1")
+	if err != nil || !strings.Contains(out, strings.TrimPrefix(peer, "http://")) {
+		t.Errorf("unexpected owner output %q, %v", out, err)
+	}
+
+	if _, err := tsctl("remove", "tsctl-group", "key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := tsctl("purge", "tsctl-group"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if group := tscache.GetGroup("tsctl-group"); group.CacheStats(tscache.MainCache).Items != 0 {
+		t.Error("expected the group to be empty")
+	}
+	if _, err := tsctl("purge", "no-such-group"); err == nil {
+		t.Error("expected purging an unknown group to fail")
+	}
+
+	if _, err := tsctl("bogus"); err == nil {
+		t.Error("expected an unknown command to fail")
+	}
+	if _, err := tsctl("get", "tsctl-group"); err == nil {
+		t.Error("expected missing arguments to fail")
+	}
+}
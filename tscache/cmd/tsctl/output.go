@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// table writes rows as aligned columns.
+type table struct {
+	tw *tabwriter.Writer
+}
+
+// newTable starts a table with the given column headers.
+func newTable(w io.Writer, headers ...string) *table {
+	t := &table{tw: tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+	fmt.Fprintln(t.tw, strings.Join(headers, "\t"))
+	return t
+}
+
+// row adds a row, formatting every cell with its default format.
+func (t *table) row(cells ...interface{}) {
+	s := make([]string, len(cells))
+	for i, cell := range cells {
+		s[i] = fmt.Sprint(cell)
+	}
+	fmt.Fprintln(t.tw, strings.Join(s, "\t"))
+}
+
+// flush writes the table.
+func (t *table) flush() error {
+	return t.tw.Flush()
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,59 @@
+// Package compress provides the codecs tscache uses to compress values, in memory and on the
+// wire, and a registry resolving the codec names negotiated between peers.
+package compress
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrCorrupt is returned when decoding data that was not produced by the codec.
+var ErrCorrupt = errors.New("compress: corrupt input")
+
+// Codec compresses and decompresses byte slices.
+type Codec interface {
+	// Name identifies the codec between peers.
+	Name() string
+	// Encode returns the compressed form of src.
+	Encode(src []byte) ([]byte, error)
+	// Decode returns the data that was compressed into src.
+	Decode(src []byte) ([]byte, error)
+}
+
+var (
+	mu     sync.RWMutex
+	codecs = make(map[string]Codec) // codecs maps codec names to the registered codecs.
+)
+
+func init() {
+	Register(LZ)
+	Register(Gzip)
+}
+
+// Register makes a codec available to peers under its name, replacing any codec of the same name.
+func Register(c Codec) {
+	mu.Lock()
+	defer mu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// Lookup returns the codec registered under name.
+func Lookup(name string) (Codec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// Names returns the names of every registered codec, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
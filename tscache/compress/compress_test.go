@@ -0,0 +1,85 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// samples returns inputs exercising literals, copies and overlapping copies.
+func samples() map[string][]byte {
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	var doc strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&doc, `{"id":%d,"name":"user-%d","active":true,"tags":["a","b"]},`, i, i%7)
+	}
+	return map[string][]byte{
+		"empty":  {},
+		"short":  []byte("abc"),
+		"run":    bytes.Repeat([]byte{'x'}, 1000),
+		"random": random,
+		"json":   []byte(doc.String()),
+	}
+}
+
+// TestCodecs tests that every codec decodes what it encodes.
+func TestCodecs(t *testing.T) {
+	for _, codec := range []Codec{LZ, Gzip, NewGzip(1)} {
+		for name, input := range samples() {
+			encoded, err := codec.Encode(input)
+			if err != nil {
+				t.Fatalf("%s/%s: unexpected error: %v", codec.Name(), name, err)
+			}
+			decoded, err := codec.Decode(encoded)
+			if err != nil {
+				t.Fatalf("%s/%s: unexpected error: %v", codec.Name(), name, err)
+			}
+			if !bytes.Equal(decoded, input) {
+				t.Errorf("%s/%s: round trip changed the data", codec.Name(), name)
+			}
+		}
+	}
+}
+
+// TestLZ_Ratio tests that LZ shrinks repetitive JSON severalfold.
+func TestLZ_Ratio(t *testing.T) {
+	input := samples()["json"]
+	encoded, _ := LZ.Encode(input)
+	if ratio := float64(len(input)) / float64(len(encoded)); ratio < 4 {
+		t.Errorf("expected a ratio of at least 4, got %.1f (%d -> %d bytes)", ratio, len(input), len(encoded))
+	}
+}
+
+// TestLZ_Corrupt tests that invalid input is rejected.
+func TestLZ_Corrupt(t *testing.T) {
+	encoded, _ := LZ.Encode(samples()["json"])
+	inputs := map[string][]byte{
+		"empty":     nil,
+		"truncated": encoded[:len(encoded)/2],
+		"long size": append([]byte{0xff, 0x01}, encoded[1:]...),
+		"bad copy":  {10, 3, 2, 5},
+	}
+	for name, input := range inputs {
+		if _, err := LZ.Decode(input); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: expected ErrCorrupt, got %v", name, err)
+		}
+	}
+}
+
+// TestRegistry tests looking codecs up by name.
+func TestRegistry(t *testing.T) {
+	if names := Names(); !reflect.DeepEqual(names, []string{"gzip", "lz"}) {
+		t.Errorf("expected gzip and lz, got %v", names)
+	}
+	if c, ok := Lookup("lz"); !ok || c != LZ {
+		t.Errorf("expected the LZ codec, got %v", c)
+	}
+	if _, ok := Lookup("zstd"); ok {
+		t.Error("expected no zstd codec")
+	}
+}
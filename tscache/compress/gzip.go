@@ -0,0 +1,52 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Gzip is the gzip codec at the default compression level.
+var Gzip Codec = gzipCodec{level: gzip.DefaultCompression}
+
+// NewGzip returns a gzip codec at the given level, from gzip.BestSpeed to gzip.BestCompression.
+// Every gzip codec decodes the output of the others, so they share the name "gzip".
+func NewGzip(level int) Codec {
+	return gzipCodec{level: level}
+}
+
+// gzipCodec is the gzip codec from the standard library.
+type gzipCodec struct {
+	level int
+}
+
+// Name returns "gzip".
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+// Encode compresses src with gzip.
+func (c gzipCodec) Encode(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decompresses gzip data.
+func (gzipCodec) Decode(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
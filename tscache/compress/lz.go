@@ -0,0 +1,122 @@
+package compress
+
+import "encoding/binary"
+
+// LZ is a fast LZ77 codec in the spirit of Snappy: it trades compression ratio for speed,
+// finding repeated sequences of at least 4 bytes through a hash table of recent positions.
+//
+// The encoded form is the decoded length as a uvarint followed by elements. An element starts
+// with a uvarint holding its length shifted left by one, with the low bit set for a copy:
+// a literal is followed by its bytes, a copy by the uvarint distance back to the bytes to repeat.
+var LZ Codec = lzCodec{}
+
+const (
+	// lzMinMatch is the shortest sequence encoded as a copy.
+	lzMinMatch = 4
+	// lzMinTableBits and lzMaxTableBits bound the size of the hash table, which follows the input size.
+	lzMinTableBits = 8
+	lzMaxTableBits = 14
+)
+
+// lzCodec implements LZ.
+type lzCodec struct{}
+
+// Name returns "lz".
+func (lzCodec) Name() string {
+	return "lz"
+}
+
+// Encode compresses src.
+func (lzCodec) Encode(src []byte) ([]byte, error) {
+	dst := binary.AppendUvarint(make([]byte, 0, len(src)/2+binary.MaxVarintLen64), uint64(len(src)))
+
+	bits := lzMinTableBits
+	for bits < lzMaxTableBits && 1<<bits < len(src) {
+		bits++
+	}
+	table := make([]int, 1<<bits) // Last position+1 of every hashed sequence; zero means none
+
+	lit := 0 // Start of the bytes not encoded yet
+	for i := 0; i+lzMinMatch <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - bits)
+		cand := table[h] - 1
+		table[h] = i + 1
+		if cand < 0 || binary.LittleEndian.Uint32(src[cand:]) != seq {
+			i++
+			continue
+		}
+
+		n := lzMinMatch
+		for i+n < len(src) && src[cand+n] == src[i+n] {
+			n++
+		}
+		dst = appendLiteral(dst, src[lit:i])
+		dst = binary.AppendUvarint(dst, uint64(n)<<1|1)
+		dst = binary.AppendUvarint(dst, uint64(i-cand))
+		i += n
+		lit = i
+	}
+	return appendLiteral(dst, src[lit:]), nil
+}
+
+// appendLiteral appends a literal element holding b, if not empty.
+func appendLiteral(dst, b []byte) []byte {
+	if len(b) == 0 {
+		return dst
+	}
+	dst = binary.AppendUvarint(dst, uint64(len(b))<<1)
+	return append(dst, b...)
+}
+
+// Decode decompresses src. Every length and distance is checked against the decoded length
+// announced by src, so that corrupt input fails instead of allocating or reading out of bounds.
+func (lzCodec) Decode(src []byte) ([]byte, error) {
+	size, k := binary.Uvarint(src)
+	if k <= 0 {
+		return nil, ErrCorrupt
+	}
+	src = src[k:]
+	// Preallocate the announced size, bounded so that a corrupt size cannot force a huge allocation
+	dst := make([]byte, 0, min(size, uint64(len(src))*64))
+
+	for len(src) > 0 {
+		header, k := binary.Uvarint(src)
+		if k <= 0 {
+			return nil, ErrCorrupt
+		}
+		src = src[k:]
+		length := header >> 1
+		if uint64(len(dst))+length > size {
+			return nil, ErrCorrupt
+		}
+
+		if header&1 == 0 {
+			if length > uint64(len(src)) {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		}
+
+		offset, k := binary.Uvarint(src)
+		if k <= 0 || offset == 0 || offset > uint64(len(dst)) {
+			return nil, ErrCorrupt
+		}
+		src = src[k:]
+		pos := len(dst) - int(offset)
+		if offset >= length {
+			dst = append(dst, dst[pos:pos+int(length)]...)
+			continue
+		}
+		// The copy overlaps the bytes it produces, e.g. a run of a repeated byte
+		for j := 0; j < int(length); j++ {
+			dst = append(dst, dst[pos+j])
+		}
+	}
+	if uint64(len(dst)) != size {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
@@ -1,10 +1,11 @@
 package consistenthash
 
 import (
-	"errors"
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
+	"sync"
 )
 
 // Hash represents a function that generates a hash value for given data.
@@ -12,15 +13,30 @@ type Hash func(data []byte) uint32
 
 // Node represents a node in the consistent hash ring.
 type Node struct {
-	Name string // Name of the node
+	Name   string // Name of the node
+	Weight int    // Relative capacity of the node; zero or less counts as 1
+}
+
+// points returns the number of virtual nodes of node on a ring with the given replicas.
+func (n *Node) points(replicas int) int {
+	if n.Weight <= 1 {
+		return replicas
+	}
+	return replicas * n.Weight
 }
 
 // Map represents the consistent hash map.
 type Map struct {
-	hash     Hash          // Hash function to use
-	replicas int           // Number of replicas of each node in the hash ring
-	keys     []int         // Sorted list of hash keys
-	hashMap  map[int]*Node // Mapping of hash keys to nodes
+	hash     Hash             // Hash function to use
+	replicas int              // Number of replicas of each node in the hash ring
+	keys     []int            // Sorted list of hash keys
+	hashMap  map[int]*Node    // Mapping of hash keys to nodes
+	nodes    map[string]*Node // Nodes on the ring, keyed by name
+
+	loadMu    sync.Mutex       // Guards the load fields, which change outside the placement's lock
+	bound     float64          // 1+ε when loads are bounded; zero disables the bound
+	loads     map[string]int64 // In-flight requests per node name
+	totalLoad int64            // Sum of loads
 }
 
 // NewMap creates and initializes a new consistent hash map.
@@ -30,6 +46,8 @@ func NewMap(replicas int, fn Hash) *Map {
 		hash:     fn,
 		replicas: replicas,
 		hashMap:  make(map[int]*Node),
+		nodes:    make(map[string]*Node),
+		loads:    make(map[string]int64),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -38,11 +56,17 @@ func NewMap(replicas int, fn Hash) *Map {
 }
 
 // Add adds nodes to the consistent hash map.
+// Each node gets replicas virtual nodes per unit of weight.
 func (m *Map) Add(nodes ...*Node) {
 	for _, node := range nodes {
-		for i := 0; i < m.replicas; i++ {
+		m.nodes[node.Name] = node
+		for i := 0; i < node.points(m.replicas); i++ {
 			// Compute hash for the node with replica index
 			hash := int(m.hash([]byte(node.Name + strconv.Itoa(i))))
+			// Skip points the node already owns so that adding a node twice is harmless
+			if n, ok := m.hashMap[hash]; ok && n.Name == node.Name {
+				continue
+			}
 			// Append hash to the list of keys
 			m.keys = append(m.keys, hash)
 			// Map the hash to the node
@@ -53,10 +77,58 @@ func (m *Map) Add(nodes ...*Node) {
 	sort.Ints(m.keys)
 }
 
+// Remove removes nodes from the consistent hash map.
+// Only the keys owned by the removed nodes move; they go to the next node on the ring.
+func (m *Map) Remove(nodes ...*Node) {
+	for _, node := range nodes {
+		// Use the node as it was added, so that all of its virtual nodes are found
+		if added, ok := m.nodes[node.Name]; ok {
+			node = added
+			delete(m.nodes, node.Name)
+		}
+		m.loadMu.Lock()
+		m.totalLoad -= m.loads[node.Name]
+		delete(m.loads, node.Name)
+		m.loadMu.Unlock()
+		for i := 0; i < node.points(m.replicas); i++ {
+			// Compute hash for the node with replica index
+			hash := int(m.hash([]byte(node.Name + strconv.Itoa(i))))
+			// Drop the point unless another node owns it
+			if n, ok := m.hashMap[hash]; ok && n.Name == node.Name {
+				delete(m.hashMap, hash)
+			}
+		}
+	}
+	// Keep the sorted keys that are still mapped, without duplicates
+	keys := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := m.hashMap[hash]; ok && (len(keys) == 0 || keys[len(keys)-1] != hash) {
+			keys = append(keys, hash)
+		}
+	}
+	m.keys = keys
+}
+
+// Point is a virtual node: a position on the ring and the name of the node owning it.
+type Point struct {
+	Hash uint32 `json:"hash"` // Position on the ring
+	Node string `json:"node"` // Name of the owning node
+}
+
+// Points returns the virtual nodes of the ring in ring order.
+// A point owns the keys hashing after the previous point, up to and including its own hash.
+func (m *Map) Points() []Point {
+	points := make([]Point, len(m.keys))
+	for i, hash := range m.keys {
+		points[i] = Point{Hash: uint32(hash), Node: m.hashMap[hash].Name}
+	}
+	return points
+}
+
 // SelectNode selects the node responsible for a given key.
 func (m *Map) SelectNode(key string) (*Node, error) {
 	if len(m.keys) == 0 {
-		return nil, errors.New("no nodes available")
+		return nil, errNoNodes
 	}
 
 	// Compute hash for the key
@@ -67,6 +139,87 @@ func (m *Map) SelectNode(key string) (*Node, error) {
 		return m.keys[i] >= hash
 	})
 
-	// Get the node mapped to the selected key's hash
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	if m.bound == 0 {
+		// Get the node mapped to the selected key's hash
+		return m.hashMap[m.keys[idx%len(m.keys)]], nil
+	}
+
+	// Walk the ring from the key's position to the first node with room for one more request
+	limit := m.maxLoad()
+	for i := 0; i < len(m.keys); i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if m.loads[node.Name]+1 <= limit {
+			return node, nil
+		}
+	}
 	return m.hashMap[m.keys[idx%len(m.keys)]], nil
 }
+
+// SetLoadBound enables consistent hashing with bounded loads (Mirrokni, Thorup and Zadimoghaddam):
+// no node takes more than (1+epsilon) times the average in-flight load, and SelectNode moves
+// keys of an overloaded node to the next node on the ring. Loads are reported with Inc and Done.
+// An epsilon of zero or less disables the bound.
+func (m *Map) SetLoadBound(epsilon float64) {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	if epsilon <= 0 {
+		m.bound = 0
+		return
+	}
+	m.bound = 1 + epsilon
+}
+
+// Inc records the start of a request served by the named node.
+func (m *Map) Inc(name string) {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	m.loads[name]++
+	m.totalLoad++
+}
+
+// Done records the end of a request started with Inc.
+func (m *Map) Done(name string) {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	if m.loads[name] <= 0 {
+		return
+	}
+	m.loads[name]--
+	m.totalLoad--
+}
+
+// Load returns the number of in-flight requests of the named node.
+func (m *Map) Load(name string) int64 {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+	return m.loads[name]
+}
+
+// maxLoad returns the largest load a node may reach when it takes one more request.
+// It must be called with m.loadMu held.
+func (m *Map) maxLoad() int64 {
+	avg := float64(m.totalLoad+1) / float64(len(m.nodes))
+	return int64(math.Ceil(avg * m.bound))
+}
+
+// Distribution returns the share of sampleKeys owned by each node, keyed by node name.
+// It is meant to verify how evenly the keyspace is spread over weighted nodes.
+func (m *Map) Distribution(sampleKeys []string) map[string]float64 {
+	counts := make(map[string]int, len(m.nodes))
+	for _, key := range sampleKeys {
+		if node, err := m.SelectNode(key); err == nil {
+			counts[node.Name]++
+		}
+	}
+	shares := make(map[string]float64, len(m.nodes))
+	for name := range m.nodes {
+		if len(sampleKeys) > 0 {
+			shares[name] = float64(counts[name]) / float64(len(sampleKeys))
+		} else {
+			shares[name] = 0
+		}
+	}
+	return shares
+}
@@ -3,6 +3,7 @@ package consistenthash
 import (
 	"hash/crc32"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
@@ -74,7 +75,7 @@ func TestGetNode(t *testing.T) {
 	})
 
 	// Add nodes to the map
-	m.Add(&Node{"2"}, &Node{"4"}, &Node{"8"})
+	m.Add(&Node{Name: "2"}, &Node{Name: "4"}, &Node{Name: "8"})
 
 	// Test node selection
 	node1, _ := m.SelectNode("2")
@@ -87,7 +88,7 @@ func TestGetNode(t *testing.T) {
 	}
 
 	// Add a new node to the map
-	m.Add(&Node{"6"})
+	m.Add(&Node{Name: "6"})
 
 	// Re-select node "5" and check if it has been updated to node "6"
 	node3, _ = m.SelectNode("5")
@@ -97,3 +98,167 @@ func TestGetNode(t *testing.T) {
 		t.Fatalf("Test failed, %s", node3.Name)
 	}
 }
+
+// TestRemove tests removing nodes from the consistent hash map.
+func TestRemove(t *testing.T) {
+	// Create a new Map instance with size 1 and a hash function converting bytes to numbers
+	m := NewMap(1, func(data []byte) uint32 {
+		return uint32(data[0] - '0')
+	})
+
+	// Add nodes to the map and remove one of them
+	m.Add(&Node{Name: "2"}, &Node{Name: "4"}, &Node{Name: "8"})
+	m.Remove(&Node{Name: "4"})
+
+	// Keys of the removed node move to the next node on the ring
+	node, _ := m.SelectNode("3")
+	if node.Name != "8" {
+		t.Fatalf("Test failed, %s", node.Name)
+	}
+	if len(m.keys) != 2 || len(m.hashMap) != 2 {
+		t.Fatalf("Expected 2 points left, got %d keys and %d mapped", len(m.keys), len(m.hashMap))
+	}
+
+	// Removing every node empties the map
+	m.Remove(&Node{Name: "2"}, &Node{Name: "8"})
+	if _, err := m.SelectNode("3"); err == nil {
+		t.Fatalf("Expected error for an empty map")
+	}
+}
+
+// TestAddMovesFewKeys tests that adding a node only moves about 1/N of the keys, all to the new node.
+func TestAddMovesFewKeys(t *testing.T) {
+	m := NewMap(50, nil)
+	m.Add(&Node{Name: "node1"}, &Node{Name: "node2"}, &Node{Name: "node3"}, &Node{Name: "node4"})
+
+	// Record the owner of each key
+	const keys = 10000
+	before := make([]string, keys)
+	for i := range before {
+		node, _ := m.SelectNode("key" + strconv.Itoa(i))
+		before[i] = node.Name
+	}
+
+	// Add a fifth node and count the keys whose owner changed
+	m.Add(&Node{Name: "node5"})
+	moved := 0
+	for i := range before {
+		node, _ := m.SelectNode("key" + strconv.Itoa(i))
+		if node.Name != before[i] {
+			if node.Name != "node5" {
+				t.Fatalf("key%d moved between existing nodes, from %s to %s", i, before[i], node.Name)
+			}
+			moved++
+		}
+	}
+	// The new node takes about 1/5 of the keyspace; allow a factor of two for the ring's imbalance
+	if share := float64(moved) / keys; share < 0.1 || share > 0.4 {
+		t.Errorf("Expected about 1/5 of the keys to move, got %.2f", share)
+	}
+
+	// Removing the node again restores the original owners
+	m.Remove(&Node{Name: "node5"})
+	for i := range before {
+		if node, _ := m.SelectNode("key" + strconv.Itoa(i)); node.Name != before[i] {
+			t.Fatalf("key%d did not return to %s after removal", i, before[i])
+		}
+	}
+}
+
+// TestWeightedNodes tests that virtual nodes and key shares follow node weights.
+func TestWeightedNodes(t *testing.T) {
+	m := NewMap(50, nil)
+	m.Add(&Node{Name: "small", Weight: 1}, &Node{Name: "large", Weight: 8})
+
+	// The large node gets 8 times as many virtual nodes
+	if len(m.keys) != 450 {
+		t.Fatalf("Expected 450 virtual nodes, got %d", len(m.keys))
+	}
+
+	// Sample the keyspace and check the shares are close to 1/9 and 8/9
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	shares := m.Distribution(keys)
+	if shares["small"] < 0.05 || shares["small"] > 0.2 || shares["large"] < 0.8 {
+		t.Errorf("Expected shares near 0.11 and 0.89, got %v", shares)
+	}
+
+	// Removing by name drops every virtual node of the weighted node
+	m.Remove(&Node{Name: "large"})
+	if len(m.keys) != 50 {
+		t.Fatalf("Expected 50 virtual nodes left, got %d", len(m.keys))
+	}
+	if shares := m.Distribution(keys); shares["small"] != 1 {
+		t.Errorf("Expected the remaining node to own every key, got %v", shares)
+	}
+}
+
+// TestBoundedLoads tests that a hot key spills over to the next nodes instead of overloading its owner.
+func TestBoundedLoads(t *testing.T) {
+	m := NewMap(50, nil)
+	m.Add(&Node{Name: "a"}, &Node{Name: "b"}, &Node{Name: "c"})
+
+	// Without a bound every request for the key goes to its owner
+	owner, _ := m.SelectNode("hot")
+	m.Inc(owner.Name)
+	if node, _ := m.SelectNode("hot"); node != owner {
+		t.Fatalf("Expected %s without a load bound, got %s", owner.Name, node.Name)
+	}
+	m.Done(owner.Name)
+
+	// With a bound, in-flight requests for the key never exceed (1+ε) times the average
+	m.SetLoadBound(0.25)
+	for i := 0; i < 90; i++ {
+		node, err := m.SelectNode("hot")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		m.Inc(node.Name)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if load := m.Load(name); load > 38 {
+			t.Errorf("Expected load of %s at most 38, got %d", name, load)
+		}
+	}
+	if m.Load(owner.Name) != 38 {
+		t.Errorf("Expected the owner to be filled up to the bound, got %d", m.Load(owner.Name))
+	}
+
+	// Once the requests end the key goes back to its owner
+	for _, name := range []string{"a", "b", "c"} {
+		for m.Load(name) > 0 {
+			m.Done(name)
+		}
+	}
+	if node, _ := m.SelectNode("hot"); node != owner {
+		t.Errorf("Expected %s once idle, got %s", owner.Name, node.Name)
+	}
+
+	// Removing a node forgets its load
+	m.Inc(owner.Name)
+	m.Remove(owner)
+	if m.Load(owner.Name) != 0 || m.totalLoad != 0 {
+		t.Errorf("Expected no load left after removal, got %d", m.totalLoad)
+	}
+}
+
+func TestPoints(t *testing.T) {
+	m := NewMap(3, nil)
+	m.Add(&Node{Name: "a"}, &Node{Name: "b", Weight: 2})
+
+	points := m.Points()
+	owned := map[string]int{}
+	for _, point := range points {
+		owned[point.Node]++
+	}
+	if len(points) != 9 || owned["a"] != 3 || owned["b"] != 6 {
+		t.Fatalf("expected 3 points of a and 6 of b, got %v", owned)
+	}
+	for i, point := range points {
+		if i > 0 && point.Hash <= points[i-1].Hash {
+			t.Fatalf("expected points in ring order, got %v", points)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package consistenthash
+
+import (
+	"errors"
+	"hash/fnv"
+)
+
+// errNoNodes is returned when selecting a node from an empty placement.
+var errNoNodes = errors.New("no nodes available")
+
+// Hash64 represents a function that generates a 64-bit hash value for given data.
+// Placements that are shared by several processes need a hash that is stable across them.
+type Hash64 func(data []byte) uint64
+
+// defaultHash64 hashes data with FNV-1a and mixes the result with the SplitMix64 finalizer,
+// so that inputs differing in a few trailing bytes still spread over the whole range.
+func defaultHash64(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,70 @@
+package consistenthash
+
+import "sort"
+
+// Jump implements jump consistent hashing (Lamping and Veach): keys map to buckets 0..n-1 with
+// no memory overhead and an even spread. Nodes are buckets sorted by name, so that nodes with
+// the same membership agree on every owner whatever order they learned it in. A node sorting
+// after every other one only takes keys when it joins, while any other join or leave shifts the
+// buckets after it and moves more keys than the other algorithms. Node weights are ignored.
+type Jump struct {
+	hash  Hash64  // Hash function to use
+	nodes []*Node // Buckets, sorted by name
+}
+
+// NewJump creates a jump consistent hash placement.
+// If hash function is not provided, it defaults to FNV-1a with a 64-bit finalizer.
+func NewJump(fn Hash64) *Jump {
+	if fn == nil {
+		fn = defaultHash64
+	}
+	return &Jump{hash: fn}
+}
+
+// Add adds nodes as new buckets, in name order. Nodes that are already present are ignored.
+func (j *Jump) Add(nodes ...*Node) {
+	for _, node := range nodes {
+		if j.index(node.Name) < 0 {
+			j.nodes = append(j.nodes, node)
+		}
+	}
+	sort.Slice(j.nodes, func(a, b int) bool { return j.nodes[a].Name < j.nodes[b].Name })
+}
+
+// Remove removes the buckets of nodes.
+func (j *Jump) Remove(nodes ...*Node) {
+	for _, node := range nodes {
+		if i := j.index(node.Name); i >= 0 {
+			j.nodes = append(j.nodes[:i], j.nodes[i+1:]...)
+		}
+	}
+}
+
+// index returns the bucket of the named node, or -1.
+func (j *Jump) index(name string) int {
+	for i, node := range j.nodes {
+		if node.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SelectNode selects the bucket of a given key.
+func (j *Jump) SelectNode(key string) (*Node, error) {
+	if len(j.nodes) == 0 {
+		return nil, errNoNodes
+	}
+	return j.nodes[jumpHash(j.hash([]byte(key)), len(j.nodes))], nil
+}
+
+// jumpHash maps key to a bucket in [0, buckets).
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
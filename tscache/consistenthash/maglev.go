@@ -0,0 +1,128 @@
+package consistenthash
+
+import "sort"
+
+// DefaultMaglevTableSize is the default size of a Maglev lookup table. It must be prime.
+const DefaultMaglevTableSize = 65537
+
+// Maglev implements Maglev hashing (Eisenbud et al.): every node fills the slots of a lookup
+// table following its own permutation, which gives an almost perfectly even spread and O(1)
+// lookups. Membership changes rebuild the table and move slightly more keys than strictly needed.
+// A node with weight w fills w slots per round.
+type Maglev struct {
+	hash  Hash64  // Hash function to use
+	size  uint64  // Number of slots in the lookup table
+	nodes []*Node // Nodes sorted by name
+	table []int   // Index in nodes of the owner of each slot
+}
+
+// NewMaglev creates a Maglev placement with a lookup table of size slots, which should be much
+// larger than the number of nodes. The size is rounded up to the next prime, so that every
+// permutation visits every slot. A non-positive size uses DefaultMaglevTableSize.
+// If hash function is not provided, it defaults to FNV-1a with a 64-bit finalizer.
+func NewMaglev(size int, fn Hash64) *Maglev {
+	if size <= 0 {
+		size = DefaultMaglevTableSize
+	}
+	if fn == nil {
+		fn = defaultHash64
+	}
+	return &Maglev{hash: fn, size: nextPrime(uint64(size))}
+}
+
+// nextPrime returns the smallest prime not below n.
+func nextPrime(n uint64) uint64 {
+	if n <= 2 {
+		return 2
+	}
+	if n%2 == 0 {
+		n++
+	}
+	for ; ; n += 2 {
+		prime := true
+		for d := uint64(3); d*d <= n; d += 2 {
+			if n%d == 0 {
+				prime = false
+				break
+			}
+		}
+		if prime {
+			return n
+		}
+	}
+}
+
+// Add adds nodes to the placement and rebuilds the lookup table.
+func (m *Maglev) Add(nodes ...*Node) {
+	for _, node := range nodes {
+		m.remove(node.Name)
+		m.nodes = append(m.nodes, node)
+	}
+	sort.Slice(m.nodes, func(i, j int) bool { return m.nodes[i].Name < m.nodes[j].Name })
+	m.populate()
+}
+
+// Remove removes nodes from the placement and rebuilds the lookup table.
+func (m *Maglev) Remove(nodes ...*Node) {
+	for _, node := range nodes {
+		m.remove(node.Name)
+	}
+	m.populate()
+}
+
+// remove drops the named node, keeping the order of the others.
+func (m *Maglev) remove(name string) {
+	for i, node := range m.nodes {
+		if node.Name == name {
+			m.nodes = append(m.nodes[:i], m.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// populate fills the lookup table, letting the nodes claim slots in turn along their permutations.
+func (m *Maglev) populate() {
+	if len(m.nodes) == 0 {
+		m.table = nil
+		return
+	}
+	offsets := make([]uint64, len(m.nodes))
+	skips := make([]uint64, len(m.nodes))
+	next := make([]uint64, len(m.nodes))
+	for i, node := range m.nodes {
+		offsets[i] = m.hash([]byte(node.Name)) % m.size
+		skips[i] = m.hash([]byte(node.Name+"#skip"))%(m.size-1) + 1
+	}
+
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+	var filled uint64
+	for {
+		for i, node := range m.nodes {
+			for w := 0; w < max(node.Weight, 1); w++ {
+				slot := (offsets[i] + next[i]*skips[i]) % m.size
+				for table[slot] >= 0 {
+					next[i]++
+					slot = (offsets[i] + next[i]*skips[i]) % m.size
+				}
+				table[slot] = i
+				next[i]++
+				filled++
+				if filled == m.size {
+					m.table = table
+					return
+				}
+			}
+		}
+	}
+}
+
+// SelectNode selects the node owning the lookup table slot of a given key.
+func (m *Maglev) SelectNode(key string) (*Node, error) {
+	if len(m.table) == 0 {
+		return nil, errNoNodes
+	}
+	return m.nodes[m.table[m.hash([]byte(key))%m.size]], nil
+}
@@ -0,0 +1,199 @@
+package consistenthash
+
+import (
+	"strconv"
+	"testing"
+)
+
+// placer is the behaviour shared by every placement algorithm of this package.
+type placer interface {
+	Add(nodes ...*Node)
+	Remove(nodes ...*Node)
+	SelectNode(key string) (*Node, error)
+}
+
+// placements creates one instance of every placement algorithm.
+func placements() map[string]func() placer {
+	return map[string]func() placer{
+		"ring":       func() placer { return NewMap(50, nil) },
+		"rendezvous": func() placer { return NewRendezvous(nil) },
+		"maglev":     func() placer { return NewMaglev(0, nil) },
+		"jump":       func() placer { return NewJump(nil) },
+	}
+}
+
+// sampleKeys returns n distinct keys.
+func sampleKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}
+
+// owners returns the name of the owner of each key.
+func owners(t *testing.T, p placer, keys []string) []string {
+	t.Helper()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		node, err := p.SelectNode(key)
+		if err != nil {
+			t.Fatalf("SelectNode(%s): %v", key, err)
+		}
+		names[i] = node.Name
+	}
+	return names
+}
+
+// TestPlacementBalance compares how evenly each algorithm spreads keys over a small cluster.
+// The ring with crc32 is the baseline the other algorithms must beat.
+func TestPlacementBalance(t *testing.T) {
+	const nodes = 5
+	keys := sampleKeys(50000)
+	maxSkew := map[string]float64{"ring": 0.6, "rendezvous": 0.05, "maglev": 0.05, "jump": 0.05}
+
+	for name, newPlacer := range placements() {
+		p := newPlacer()
+		for i := 0; i < nodes; i++ {
+			p.Add(&Node{Name: "node" + strconv.Itoa(i)})
+		}
+
+		counts := make(map[string]int)
+		for _, owner := range owners(t, p, keys) {
+			counts[owner]++
+		}
+		ideal := float64(len(keys)) / nodes
+		skew := 0.0
+		for _, c := range counts {
+			skew = max(skew, abs(float64(c)-ideal)/ideal)
+		}
+		t.Logf("%-10s max deviation from an even share: %5.1f%%", name, skew*100)
+		if len(counts) != nodes || skew > maxSkew[name] {
+			t.Errorf("%s: expected every node within %.0f%% of an even share, got %v", name, maxSkew[name]*100, counts)
+		}
+	}
+}
+
+// TestPlacementMovement compares how many keys move when a node joins and leaves.
+func TestPlacementMovement(t *testing.T) {
+	const nodes = 5
+	keys := sampleKeys(50000)
+
+	for name, newPlacer := range placements() {
+		p := newPlacer()
+		for i := 0; i < nodes; i++ {
+			p.Add(&Node{Name: "node" + strconv.Itoa(i)})
+		}
+		before := owners(t, p, keys)
+
+		// A joining node takes about 1/6 of the keys, from the others only.
+		p.Add(&Node{Name: "node" + strconv.Itoa(nodes)})
+		joined := owners(t, p, keys)
+		moved, strayed := 0, 0
+		for i := range keys {
+			if joined[i] != before[i] {
+				moved++
+				if joined[i] != "node5" {
+					strayed++
+				}
+			}
+		}
+		share := float64(moved) / float64(len(keys))
+
+		// A leaving node in the middle of the membership gives its keys away.
+		p.Remove(&Node{Name: "node2"})
+		left := owners(t, p, keys)
+		movedOnLeave := 0
+		for i := range keys {
+			if left[i] != joined[i] {
+				movedOnLeave++
+			}
+		}
+		t.Logf("%-10s join moves %5.1f%% (%d between old nodes), leave moves %5.1f%%",
+			name, share*100, strayed, float64(movedOnLeave)/float64(len(keys))*100)
+
+		if share > 0.3 {
+			t.Errorf("%s: expected about 1/6 of the keys to move on join, got %.2f", name, share)
+		}
+		if name != "maglev" && strayed != 0 {
+			t.Errorf("%s: expected keys to move only to the new node, %d moved between old nodes", name, strayed)
+		}
+		if name != "jump" && float64(movedOnLeave)/float64(len(keys)) > 0.3 {
+			t.Errorf("%s: expected about 1/6 of the keys to move on leave, got %d", name, movedOnLeave)
+		}
+	}
+}
+
+// TestPlacementWeights tests that weighted algorithms honor node weights.
+func TestPlacementWeights(t *testing.T) {
+	keys := sampleKeys(50000)
+	for _, name := range []string{"rendezvous", "maglev"} {
+		p := placements()[name]()
+		p.Add(&Node{Name: "small", Weight: 1}, &Node{Name: "large", Weight: 3})
+		large := 0
+		for _, owner := range owners(t, p, keys) {
+			if owner == "large" {
+				large++
+			}
+		}
+		if share := float64(large) / float64(len(keys)); share < 0.7 || share > 0.8 {
+			t.Errorf("%s: expected the large node to own about 3/4 of the keys, got %.2f", name, share)
+		}
+	}
+}
+
+// TestPlacementEmpty tests that every algorithm reports an empty membership.
+func TestPlacementEmpty(t *testing.T) {
+	for name, newPlacer := range placements() {
+		p := newPlacer()
+		p.Add(&Node{Name: "node"})
+		p.Remove(&Node{Name: "node"})
+		if _, err := p.SelectNode("key"); err == nil {
+			t.Errorf("%s: expected an error without nodes", name)
+		}
+	}
+}
+
+// TestPlacementOrder tests that nodes agree on every owner whatever order they added the
+// members in, and after a member left and came back.
+func TestPlacementOrder(t *testing.T) {
+	keys := sampleKeys(1000)
+	for name, newPlacer := range placements() {
+		forward, backward := newPlacer(), newPlacer()
+		for i := 0; i < 5; i++ {
+			forward.Add(&Node{Name: "node" + strconv.Itoa(i)})
+			backward.Add(&Node{Name: "node" + strconv.Itoa(4-i)})
+		}
+		forward.Remove(&Node{Name: "node1"})
+		forward.Add(&Node{Name: "node1"})
+		a, b := owners(t, forward, keys), owners(t, backward, keys)
+		for i := range keys {
+			if a[i] != b[i] {
+				t.Errorf("%s: %s owned by %s and %s depending on the order of membership", name, keys[i], a[i], b[i])
+				break
+			}
+		}
+	}
+}
+
+// TestMaglevTableSize tests that table sizes are rounded up to a prime, so that tiny and
+// composite sizes still fill the table.
+func TestMaglevTableSize(t *testing.T) {
+	for size, want := range map[int]uint64{1: 2, 2: 2, 100: 101, 65536: DefaultMaglevTableSize} {
+		m := NewMaglev(size, nil)
+		if m.size != want {
+			t.Errorf("NewMaglev(%d): table size %d, want %d", size, m.size, want)
+		}
+		m.Add(&Node{Name: "a"}, &Node{Name: "b"}, &Node{Name: "c"})
+		if _, err := m.SelectNode("key"); err != nil {
+			t.Errorf("NewMaglev(%d): SelectNode: %v", size, err)
+		}
+	}
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,70 @@
+package consistenthash
+
+import (
+	"math"
+	"sort"
+)
+
+// Rendezvous implements highest random weight (HRW) hashing: every node scores each key and the
+// highest score wins. Adding or removing a node only moves the keys that node wins or loses.
+// Weighted nodes use logarithmic scoring, so a node's share is proportional to its weight.
+type Rendezvous struct {
+	hash  Hash64  // Hash function to use
+	nodes []*Node // Nodes sorted by name
+}
+
+// NewRendezvous creates a rendezvous placement.
+// If hash function is not provided, it defaults to FNV-1a with a 64-bit finalizer.
+func NewRendezvous(fn Hash64) *Rendezvous {
+	if fn == nil {
+		fn = defaultHash64
+	}
+	return &Rendezvous{hash: fn}
+}
+
+// Add adds nodes to the placement. Nodes that are already present are replaced.
+func (r *Rendezvous) Add(nodes ...*Node) {
+	for _, node := range nodes {
+		r.remove(node.Name)
+		r.nodes = append(r.nodes, node)
+	}
+	sort.Slice(r.nodes, func(i, j int) bool { return r.nodes[i].Name < r.nodes[j].Name })
+}
+
+// Remove removes nodes from the placement.
+func (r *Rendezvous) Remove(nodes ...*Node) {
+	for _, node := range nodes {
+		r.remove(node.Name)
+	}
+}
+
+// remove drops the named node, keeping the order of the others.
+func (r *Rendezvous) remove(name string) {
+	for i, node := range r.nodes {
+		if node.Name == name {
+			r.nodes = append(r.nodes[:i], r.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+// SelectNode selects the node with the highest score for a given key.
+func (r *Rendezvous) SelectNode(key string) (*Node, error) {
+	if len(r.nodes) == 0 {
+		return nil, errNoNodes
+	}
+	var (
+		best      *Node
+		bestScore = math.Inf(-1)
+	)
+	for _, node := range r.nodes {
+		h := r.hash([]byte(node.Name + "\x00" + key))
+		// Map the hash to a uniform value in (0, 1) and score it as weight / -ln(u)
+		u := (float64(h>>11) + 0.5) / (1 << 53)
+		score := float64(max(node.Weight, 1)) / -math.Log(u)
+		if score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best, nil
+}
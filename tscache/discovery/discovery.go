@@ -0,0 +1,124 @@
+// Package discovery keeps the membership of a peer pool in sync with an external source of
+// peer addresses, such as a local file, DNS SRV records or an environment variable.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"tscache/consistenthash"
+)
+
+// DefaultInterval is the polling interval used when none is given.
+const DefaultInterval = 5 * time.Second
+
+// Discoverer looks up the current peers of a cluster.
+type Discoverer interface {
+	// Discover returns every peer of the cluster, including the current node.
+	Discover(ctx context.Context) ([]*consistenthash.Node, error)
+}
+
+// DiscovererFunc is a function type that implements the Discoverer interface.
+type DiscovererFunc func(ctx context.Context) ([]*consistenthash.Node, error)
+
+// Discover calls f(ctx).
+func (f DiscovererFunc) Discover(ctx context.Context) ([]*consistenthash.Node, error) {
+	return f(ctx)
+}
+
+// Membership is the peer pool updated by discovery. tscache.HTTPPool satisfies it:
+// Set replaces the whole membership at once.
+type Membership interface {
+	Set(nodes ...*consistenthash.Node)
+}
+
+// Start applies the peers found by d to m, then polls d every interval until ctx is done.
+// The membership is only updated when the peers change, and a failed lookup keeps the current
+// membership. Start returns the error of the first lookup so that a bad source fails at startup.
+func Start(ctx context.Context, d Discoverer, m Membership, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	nodes, err := d.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	m.Set(nodes...)
+	go poll(ctx, d, m, interval, nodes)
+	return nil
+}
+
+// poll runs the lookups of Start until ctx is done.
+func poll(ctx context.Context, d Discoverer, m Membership, interval time.Duration, last []*consistenthash.Node) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		nodes, err := d.Discover(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[discovery] lookup failed, keeping %d peers: %v", len(last), err)
+			}
+			continue
+		}
+		if !Equal(nodes, last) {
+			m.Set(nodes...)
+			last = nodes
+		}
+	}
+}
+
+// Equal reports whether a and b hold the same peers with the same weights, in any order.
+func Equal(a, b []*consistenthash.Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return slices.Equal(keys(a), keys(b))
+}
+
+// keys returns the sorted name and weight of every node.
+func keys(nodes []*consistenthash.Node) []string {
+	ks := make([]string, len(nodes))
+	for i, node := range nodes {
+		ks[i] = node.Name + " " + strconv.Itoa(max(node.Weight, 1))
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+// parseList parses peers separated by newlines or commas. Each peer is an address optionally
+// followed by whitespace and a weight; blank entries and lines starting with '#' are ignored.
+func parseList(text string) ([]*consistenthash.Node, error) {
+	var nodes []*consistenthash.Node
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, entry := range strings.Split(line, ",") {
+			fields := strings.Fields(entry)
+			switch len(fields) {
+			case 0:
+				continue
+			case 1:
+				nodes = append(nodes, &consistenthash.Node{Name: fields[0]})
+			case 2:
+				weight, err := strconv.Atoi(fields[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid weight for peer %s: %w", fields[0], err)
+				}
+				nodes = append(nodes, &consistenthash.Node{Name: fields[0], Weight: weight})
+			default:
+				return nil, fmt.Errorf("invalid peer entry %q", strings.TrimSpace(entry))
+			}
+		}
+	}
+	return nodes, nil
+}
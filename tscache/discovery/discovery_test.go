@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"tscache"
+	"tscache/consistenthash"
+)
+
+var _ Membership = (*tscache.HTTPPool)(nil)
+
+// recorder is a Membership that records every update.
+type recorder struct {
+	mu   sync.Mutex
+	sets [][]*consistenthash.Node
+}
+
+func (r *recorder) Set(nodes ...*consistenthash.Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sets = append(r.sets, nodes)
+}
+
+func (r *recorder) updates() [][]*consistenthash.Node {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]*consistenthash.Node(nil), r.sets...)
+}
+
+// TestStart tests that membership is updated only when the discovered peers change.
+func TestStart(t *testing.T) {
+	var mu sync.Mutex
+	peers, err := []*consistenthash.Node{{Name: "a"}}, error(nil)
+	d := DiscovererFunc(func(ctx context.Context) ([]*consistenthash.Node, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return peers, err
+	})
+	set := func(nodes []*consistenthash.Node, e error) {
+		mu.Lock()
+		defer mu.Unlock()
+		peers, err = nodes, e
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &recorder{}
+	if err := Start(ctx, d, r, 5*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := r.updates(); len(got) != 1 || len(got[0]) != 1 {
+		t.Fatalf("expected the initial membership to be applied, got %v", got)
+	}
+
+	// Unchanged peers and failed lookups leave the membership alone
+	time.Sleep(20 * time.Millisecond)
+	set(nil, errors.New("lookup failed"))
+	time.Sleep(20 * time.Millisecond)
+	if got := r.updates(); len(got) != 1 {
+		t.Fatalf("expected no update, got %d", len(got))
+	}
+
+	set([]*consistenthash.Node{{Name: "b"}, {Name: "a"}}, nil)
+	deadline := time.Now().Add(time.Second)
+	for len(r.updates()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("membership was not updated")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := r.updates()[1]; len(got) != 2 {
+		t.Errorf("expected 2 peers, got %d", len(got))
+	}
+}
+
+// TestStartError tests that a failing first lookup is returned.
+func TestStartError(t *testing.T) {
+	d := DiscovererFunc(func(ctx context.Context) ([]*consistenthash.Node, error) {
+		return nil, errors.New("no source")
+	})
+	r := &recorder{}
+	if err := Start(context.Background(), d, r, time.Second); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(r.updates()) != 0 {
+		t.Error("expected no membership update")
+	}
+}
+
+// TestEqual tests that peer lists are compared regardless of order and of implicit weights.
+func TestEqual(t *testing.T) {
+	a := []*consistenthash.Node{{Name: "x"}, {Name: "y", Weight: 2}}
+	b := []*consistenthash.Node{{Name: "y", Weight: 2}, {Name: "x", Weight: 1}}
+	if !Equal(a, b) {
+		t.Error("expected equal peer lists")
+	}
+	if Equal(a, []*consistenthash.Node{{Name: "x"}, {Name: "y"}}) {
+		t.Error("expected a weight change to be detected")
+	}
+}
+
+// TestEnv tests reading peers from an environment variable.
+func TestEnv(t *testing.T) {
+	t.Setenv("TSCACHE_TEST_PEERS", "http://a:1, http://b:2 3,")
+	nodes, err := (&Env{Var: "TSCACHE_TEST_PEERS"}).Discover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*consistenthash.Node{{Name: "http://a:1"}, {Name: "http://b:2", Weight: 3}}
+	if !Equal(nodes, want) {
+		t.Errorf("expected %v, got %v", keys(want), keys(nodes))
+	}
+
+	if _, err := (&Env{Var: "TSCACHE_TEST_UNSET"}).Discover(context.Background()); err == nil {
+		t.Error("expected an error for an unset variable")
+	}
+}
@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"tscache/consistenthash"
+)
+
+// Resolver looks up DNS SRV records. *net.Resolver satisfies it; tests and local setups can
+// provide a stub instead.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRV discovers peers from the DNS SRV records of _service._proto.name.
+// Every record becomes a peer named scheme://target:port, weighted by the record's weight.
+type SRV struct {
+	Service  string   // Service of the records, e.g. "tscache"; empty looks up name directly
+	Proto    string   // Protocol of the records, e.g. "tcp"
+	Name     string   // Domain of the records
+	Scheme   string   // Scheme of the peer addresses; defaults to "http"
+	Resolver Resolver // Resolver used for lookups; defaults to net.DefaultResolver
+}
+
+// Discover looks up the SRV records.
+func (s *SRV) Discover(ctx context.Context) ([]*consistenthash.Node, error) {
+	var resolver Resolver = net.DefaultResolver
+	if s.Resolver != nil {
+		resolver = s.Resolver
+	}
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, s.Service, s.Proto, s.Name)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*consistenthash.Node, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		nodes = append(nodes, &consistenthash.Node{
+			Name:   scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+			Weight: int(srv.Weight),
+		})
+	}
+	return nodes, nil
+}
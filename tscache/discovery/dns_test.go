@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"tscache/consistenthash"
+)
+
+// stubResolver answers SRV lookups from a fixed table.
+type stubResolver map[string][]*net.SRV
+
+func (r stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname := "_" + service + "._" + proto + "." + name
+	records, ok := r[cname]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: cname, IsNotFound: true}
+	}
+	return cname, records, nil
+}
+
+// TestSRV tests turning SRV records into peers.
+func TestSRV(t *testing.T) {
+	resolver := stubResolver{
+		"_tscache._tcp.cache.local": {
+			{Target: "node1.cache.local.", Port: 8001, Weight: 1},
+			{Target: "node2.cache.local.", Port: 8002, Weight: 4},
+		},
+	}
+	d := &SRV{Service: "tscache", Proto: "tcp", Name: "cache.local", Resolver: resolver}
+	nodes, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []*consistenthash.Node{
+		{Name: "http://node1.cache.local:8001"},
+		{Name: "http://node2.cache.local:8002", Weight: 4},
+	}
+	if !Equal(nodes, want) {
+		t.Errorf("expected %v, got %v", keys(want), keys(nodes))
+	}
+
+	d.Name = "missing.local"
+	if _, err := d.Discover(context.Background()); err == nil {
+		t.Error("expected an error for a missing name")
+	}
+}
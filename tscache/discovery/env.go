@@ -0,0 +1,24 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"tscache/consistenthash"
+)
+
+// Env discovers peers from an environment variable holding a comma-separated list of
+// addresses, each optionally followed by a space and a weight, e.g.
+// "http://localhost:8001,http://localhost:8002 2".
+type Env struct {
+	Var string // Name of the environment variable
+}
+
+// Discover parses the environment variable. An unset variable is an error.
+func (e *Env) Discover(ctx context.Context) ([]*consistenthash.Node, error) {
+	value, ok := os.LookupEnv(e.Var)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", e.Var)
+	}
+	return parseList(value)
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"tscache/consistenthash"
+)
+
+// File discovers peers from a local file, read again on every lookup so that edits are picked
+// up by Start. The file is either a JSON array or a list of peers, one per line.
+//
+// A JSON array holds addresses or objects with a name and an optional weight:
+//
+//	["http://localhost:8001", {"name": "http://localhost:8002", "weight": 2}]
+//
+// A list holds one address per line, optionally followed by a weight; '#' starts a comment line:
+//
+//	http://localhost:8001
+//	http://localhost:8002 2
+type File struct {
+	Path string // Path of the peers file
+}
+
+// Discover reads and parses the peers file.
+func (f *File) Discover(ctx context.Context) ([]*consistenthash.Node, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("peers file %s: %w", f.Path, err)
+	}
+	return nodes, nil
+}
+
+// fileNode is a peer written as a JSON object.
+type fileNode struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// parseFile parses the content of a peers file in either format.
+func parseFile(data []byte) ([]*consistenthash.Node, error) {
+	data = bytes.TrimSpace(data)
+	if !bytes.HasPrefix(data, []byte("[")) {
+		return parseList(string(data))
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	nodes := make([]*consistenthash.Node, 0, len(entries))
+	for _, entry := range entries {
+		var n fileNode
+		if err := json.Unmarshal(entry, &n.Name); err != nil {
+			if err := json.Unmarshal(entry, &n); err != nil {
+				return nil, err
+			}
+		}
+		if n.Name == "" {
+			return nil, fmt.Errorf("peer without a name: %s", entry)
+		}
+		nodes = append(nodes, &consistenthash.Node{Name: n.Name, Weight: n.Weight})
+	}
+	return nodes, nil
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"tscache/consistenthash"
+)
+
+// TestFile_Formats tests parsing JSON and line-based peers files.
+func TestFile_Formats(t *testing.T) {
+	want := []*consistenthash.Node{{Name: "http://a:1"}, {Name: "http://b:2", Weight: 2}}
+	tests := map[string]string{
+		"json": `["http://a:1", {"name": "http://b:2", "weight": 2}]`,
+		"list": "# peers\nhttp://a:1\n\nhttp://b:2 2\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "peers")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		nodes, err := (&File{Path: path}).Discover(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !Equal(nodes, want) {
+			t.Errorf("%s: expected %v, got %v", name, keys(want), keys(nodes))
+		}
+	}
+
+	for _, content := range []string{`[{"weight": 2}]`, "http://a:1 heavy", "http://a:1 2 3", "[1"} {
+		path := filepath.Join(t.TempDir(), "peers")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (&File{Path: path}).Discover(context.Background()); err == nil {
+			t.Errorf("expected an error for %q", content)
+		}
+	}
+}
+
+// TestFile_Reload tests that edits to the peers file reach the membership.
+func TestFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers")
+	if err := os.WriteFile(path, []byte("http://a:1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &recorder{}
+	if err := Start(ctx, &File{Path: path}, r, 5*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("http://a:1\nhttp://b:2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(r.updates()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the new peers file was not applied")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := r.updates()[1]; len(got) != 2 {
+		t.Errorf("expected 2 peers, got %d", len(got))
+	}
+}
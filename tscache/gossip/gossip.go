@@ -0,0 +1,598 @@
+// Package gossip tracks the members of a cache cluster without an external registry, using the
+// SWIM protocol (Das, Gupta and Motivala) over UDP.
+//
+// Every probe interval a member pings another one. If no ack comes back within the probe timeout,
+// it asks a few other members to ping the target on its behalf (ping-req). A target answering
+// neither way becomes suspect, and a suspect that does not refute the suspicion within the
+// suspicion timeout, by gossiping a higher incarnation number, is declared dead. Membership
+// updates are piggybacked on the probe messages rather than sent on their own.
+//
+// Members joining and leaving are reported to a Pool, such as tscache.HTTPPool, so that the
+// peer ring follows the cluster.
+package gossip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tscache/consistenthash"
+)
+
+const (
+	defaultBindAddr         = ":7946"
+	defaultProbeInterval    = time.Second
+	defaultProbeTimeout     = 500 * time.Millisecond
+	defaultSuspicionTimeout = 5 * time.Second
+	defaultSyncInterval     = 30 * time.Second
+	defaultIndirectChecks   = 3
+	defaultRetransmitMult   = 4
+
+	// maxPacketSize is the largest UDP payload.
+	maxPacketSize = 65507
+)
+
+// ErrClosed is returned by the operations of a closed Memberlist.
+var ErrClosed = errors.New("gossip: memberlist is closed")
+
+// Pool is the peer pool fed by membership changes. tscache.HTTPPool satisfies it.
+type Pool interface {
+	AddPeers(nodes ...*consistenthash.Node)
+	RemovePeers(names ...string)
+}
+
+// Config configures a member of the cluster.
+type Config struct {
+	Name             string        // Name is the peer address of this node on the cache's peer ring.
+	Weight           int           // Weight is the relative capacity of this node on the peer ring.
+	BindAddr         string        // BindAddr is the UDP address to listen on; it defaults to ":7946".
+	AdvertiseAddr    string        // AdvertiseAddr is the UDP address other members reach this node on; it defaults to the bound address.
+	Pool             Pool          // Pool, if set, receives the members joining and leaving, starting with this node.
+	ProbeInterval    time.Duration // ProbeInterval is the time between two probes of this node.
+	ProbeTimeout     time.Duration // ProbeTimeout is how long a direct ping waits before asking other members.
+	SuspicionTimeout time.Duration // SuspicionTimeout is how long a suspect has to refute before it is declared dead.
+	SyncInterval     time.Duration // SyncInterval is the time between two full membership exchanges with a random member.
+	IndirectChecks   int           // IndirectChecks is the number of members asked to ping an unresponsive target.
+	RetransmitMult   int           // RetransmitMult scales how many times an update is gossiped, times log10 of the cluster size.
+
+	dropTo func(addr string) bool // dropTo, if set, drops the datagrams sent to addr; tests use it to cut links
+}
+
+// withDefaults fills unset options with their defaults.
+func (c Config) withDefaults() Config {
+	if c.BindAddr == "" {
+		c.BindAddr = defaultBindAddr
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = defaultProbeInterval
+	}
+	if c.ProbeTimeout <= 0 {
+		c.ProbeTimeout = defaultProbeTimeout
+	}
+	if c.ProbeTimeout >= c.ProbeInterval {
+		c.ProbeTimeout = c.ProbeInterval / 2
+	}
+	if c.SuspicionTimeout <= 0 {
+		c.SuspicionTimeout = defaultSuspicionTimeout
+	}
+	if c.SyncInterval <= 0 {
+		c.SyncInterval = defaultSyncInterval
+	}
+	if c.IndirectChecks <= 0 {
+		c.IndirectChecks = defaultIndirectChecks
+	}
+	if c.RetransmitMult <= 0 {
+		c.RetransmitMult = defaultRetransmitMult
+	}
+	return c
+}
+
+// Memberlist is this node's view of the cluster and the SWIM protocol maintaining it.
+type Memberlist struct {
+	cfg  Config
+	conn *net.UDPConn
+	seq  atomic.Uint64 // Last sequence number of a ping or join
+
+	mu       sync.Mutex
+	self     *Member
+	members  map[string]*Member     // Every other known member, including dead ones
+	queue    broadcasts             // Updates to piggyback
+	acks     map[uint64]func()      // Handlers of the awaited acks, keyed by sequence number
+	suspects map[string]*time.Timer // Suspicion timeouts, keyed by member name
+	probes   []string               // Members left to probe in the current round
+	events   []event                // Membership changes not yet reported to the pool
+	leaving  bool
+
+	notify    chan struct{} // Signals pending events to the dispatcher
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// event is a member joining or leaving the peer ring.
+type event struct {
+	join   bool
+	member Member
+}
+
+// New starts a member listening on cfg.BindAddr. It forms a cluster of one until Join is called.
+func New(cfg Config) (*Memberlist, error) {
+	cfg = cfg.withDefaults()
+	if cfg.Name == "" {
+		return nil, errors.New("gossip: a member name is required")
+	}
+	addr, err := net.ResolveUDPAddr("udp", cfg.BindAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AdvertiseAddr == "" {
+		local := conn.LocalAddr().(*net.UDPAddr)
+		if local.IP.IsUnspecified() {
+			conn.Close()
+			return nil, fmt.Errorf("gossip: an advertise address is required when binding to %s", local)
+		}
+		cfg.AdvertiseAddr = local.String()
+	}
+
+	m := &Memberlist{
+		cfg:      cfg,
+		conn:     conn,
+		self:     &Member{Name: cfg.Name, Addr: cfg.AdvertiseAddr, Weight: cfg.Weight, State: StateAlive},
+		members:  make(map[string]*Member),
+		queue:    broadcasts{mult: cfg.RetransmitMult},
+		acks:     make(map[uint64]func()),
+		suspects: make(map[string]*time.Timer),
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	m.mu.Lock()
+	m.emitLocked(true, m.self)
+	m.mu.Unlock()
+
+	m.wg.Add(3)
+	go m.readLoop()
+	go m.probeLoop()
+	go m.dispatch()
+	return m, nil
+}
+
+// Join contacts the members at the given UDP addresses and merges their view of the cluster.
+// It returns the number of members that answered, and an error if none did.
+func (m *Memberlist) Join(addrs ...string) (int, error) {
+	joined := 0
+	var errs []error
+	for _, addr := range addrs {
+		seq := m.seq.Add(1)
+		answered := make(chan struct{})
+		m.expectAck(seq, m.cfg.ProbeInterval, func() { close(answered) })
+
+		m.sendTo(addr, &message{Type: msgJoin, Seq: seq, Members: m.snapshot()})
+
+		select {
+		case <-answered:
+			joined++
+		case <-time.After(m.cfg.ProbeInterval):
+			errs = append(errs, fmt.Errorf("gossip: no answer from %s", addr))
+		case <-m.stop:
+			return joined, ErrClosed
+		}
+	}
+	if joined == 0 && len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	return joined, nil
+}
+
+// Leave tells the cluster that this node leaves, then closes it. The other members drop it from
+// their peer rings at once instead of waiting for the failure detector.
+func (m *Memberlist) Leave() error {
+	m.mu.Lock()
+	m.leaving = true
+	m.self.State = StateLeft
+	left := m.self.toUpdate()
+	var addrs []string
+	for _, member := range m.members {
+		if member.active() {
+			addrs = append(addrs, member.Addr)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, addr := range addrs {
+		m.sendTo(addr, &message{Type: msgGossip, Updates: []update{left}})
+	}
+	return m.Close()
+}
+
+// Close stops the member without telling the cluster, which detects the failure.
+func (m *Memberlist) Close() error {
+	err := ErrClosed
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		err = m.conn.Close()
+		m.wg.Wait()
+		m.mu.Lock()
+		for _, t := range m.suspects {
+			t.Stop()
+		}
+		m.mu.Unlock()
+	})
+	return err
+}
+
+// LocalAddr returns the UDP address this node gossips on.
+func (m *Memberlist) LocalAddr() string {
+	return m.cfg.AdvertiseAddr
+}
+
+// Members returns every known member, this node included, sorted by name.
+// Dead and left members are kept so that stale news about them is ignored.
+func (m *Memberlist) Members() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := make([]Member, 0, len(m.members)+1)
+	members = append(members, *m.self)
+	for _, member := range m.members {
+		members = append(members, *member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// readLoop handles incoming datagrams until the member is closed.
+func (m *Memberlist) readLoop() {
+	defer m.wg.Done()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		var msg message
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			m.logf("invalid message from %s: %v", from, err)
+			continue
+		}
+		m.handle(&msg, from.String())
+	}
+}
+
+// handle applies the updates carried by msg and answers it.
+func (m *Memberlist) handle(msg *message, from string) {
+	m.mu.Lock()
+	for _, u := range msg.Updates {
+		m.applyLocked(u)
+	}
+	for _, u := range msg.Members {
+		m.applyLocked(u)
+	}
+	m.mu.Unlock()
+
+	switch msg.Type {
+	case msgPing:
+		if msg.Target == "" || msg.Target == m.cfg.Name {
+			m.sendTo(from, &message{Type: msgAck, Seq: msg.Seq})
+		}
+	case msgPingReq:
+		// Ping the target with a sequence number of our own and relay its ack to the requester
+		seq, origin := m.seq.Add(1), msg.Seq
+		m.expectAck(seq, m.cfg.ProbeTimeout, func() {
+			m.sendTo(from, &message{Type: msgAck, Seq: origin})
+		})
+		m.sendTo(msg.TargetAddr, &message{Type: msgPing, Seq: seq, Target: msg.Target})
+	case msgAck, msgSync:
+		m.ack(msg.Seq)
+	case msgJoin:
+		m.sendTo(from, &message{Type: msgSync, Seq: msg.Seq, Members: m.snapshot()})
+	}
+}
+
+// snapshot returns the whole membership as updates.
+func (m *Memberlist) snapshot() []update {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	updates := make([]update, 0, len(m.members)+1)
+	updates = append(updates, m.self.toUpdate())
+	for _, member := range m.members {
+		updates = append(updates, member.toUpdate())
+	}
+	return updates
+}
+
+// applyLocked merges u into the membership. It must be called with m.mu held.
+func (m *Memberlist) applyLocked(u update) {
+	if u.Name == m.self.Name {
+		m.refuteLocked(u)
+		return
+	}
+
+	cur, ok := m.members[u.Name]
+	if !ok {
+		// Suspicions about unknown members are not news worth keeping
+		if u.State == StateSuspect {
+			return
+		}
+		cur = &Member{Name: u.Name, Addr: u.Addr, Weight: u.Weight, Incarnation: u.Incarnation, State: u.State}
+		m.members[u.Name] = cur
+		m.queue.add(u)
+		if cur.active() {
+			m.emitLocked(true, cur)
+		}
+		return
+	}
+	if !u.overrides(cur) {
+		return
+	}
+
+	wasActive := cur.active()
+	cur.Incarnation = u.Incarnation
+	cur.State = u.State
+	if u.State == StateAlive {
+		cur.Addr = u.Addr
+		cur.Weight = u.Weight
+	}
+	m.queue.add(cur.toUpdate())
+
+	if t := m.suspects[u.Name]; t != nil {
+		t.Stop()
+		delete(m.suspects, u.Name)
+	}
+	if u.State == StateSuspect {
+		m.startSuspicionLocked(u.Name, u.Incarnation)
+	}
+
+	switch {
+	case !wasActive && cur.active():
+		m.emitLocked(true, cur)
+	case wasActive && !cur.active():
+		m.emitLocked(false, cur)
+	}
+}
+
+// refuteLocked answers news about this node: a suspicion, a death or a stale incarnation is
+// overridden by gossiping this node alive with a higher incarnation. It must be called with m.mu held.
+func (m *Memberlist) refuteLocked(u update) {
+	if m.leaving || u.Incarnation < m.self.Incarnation {
+		return
+	}
+	if u.State == StateAlive && u.Incarnation == m.self.Incarnation {
+		return
+	}
+	m.self.Incarnation = u.Incarnation + 1
+	m.queue.add(m.self.toUpdate())
+}
+
+// startSuspicionLocked declares the named member dead unless it refutes the suspicion at
+// incarnation inc in time. It must be called with m.mu held.
+func (m *Memberlist) startSuspicionLocked(name string, inc uint64) {
+	m.suspects[name] = time.AfterFunc(m.cfg.SuspicionTimeout, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		cur := m.members[name]
+		if cur == nil || cur.State != StateSuspect || cur.Incarnation != inc {
+			return
+		}
+		m.logf("member %s is dead", name)
+		m.applyLocked(update{Name: name, Addr: cur.Addr, Weight: cur.Weight, Incarnation: inc, State: StateDead})
+	})
+}
+
+// emitLocked queues a membership change for the pool. It must be called with m.mu held.
+func (m *Memberlist) emitLocked(join bool, member *Member) {
+	m.events = append(m.events, event{join: join, member: *member})
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch reports membership changes to the pool, in order and outside of m.mu.
+func (m *Memberlist) dispatch() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-m.notify:
+		}
+		m.mu.Lock()
+		events := m.events
+		m.events = nil
+		m.mu.Unlock()
+		if m.cfg.Pool == nil {
+			continue
+		}
+		for _, e := range events {
+			if e.join {
+				m.cfg.Pool.AddPeers(&consistenthash.Node{Name: e.member.Name, Weight: e.member.Weight})
+			} else {
+				m.cfg.Pool.RemovePeers(e.member.Name)
+			}
+		}
+	}
+}
+
+// probeLoop probes one member every probe interval and exchanges the whole membership with
+// one member every sync interval, until the member is closed. The exchanges repair the views
+// of members that missed some piggybacked updates.
+func (m *Memberlist) probeLoop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.cfg.ProbeInterval)
+	defer ticker.Stop()
+	syncTicker := time.NewTicker(m.cfg.SyncInterval)
+	defer syncTicker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.probe()
+		case <-syncTicker.C:
+			if peers := m.randomMembers(1, ""); len(peers) > 0 {
+				m.sendTo(peers[0].Addr, &message{Type: msgJoin, Seq: m.seq.Add(1), Members: m.snapshot()})
+			}
+		}
+	}
+}
+
+// probe runs one round of the failure detector: a direct ping, then indirect pings through
+// other members, and finally a suspicion if the target answered neither.
+func (m *Memberlist) probe() {
+	m.mu.Lock()
+	target, ok := m.nextTargetLocked()
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	seq := m.seq.Add(1)
+	acked := make(chan struct{})
+	m.expectAck(seq, m.cfg.ProbeInterval, func() { close(acked) })
+	m.sendTo(target.Addr, &message{Type: msgPing, Seq: seq, Target: target.Name})
+	if m.wait(acked, m.cfg.ProbeTimeout) {
+		return
+	}
+
+	for _, peer := range m.randomMembers(m.cfg.IndirectChecks, target.Name) {
+		m.sendTo(peer.Addr, &message{Type: msgPingReq, Seq: seq, Target: target.Name, TargetAddr: target.Addr})
+	}
+	if m.wait(acked, m.cfg.ProbeInterval-m.cfg.ProbeTimeout) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.applyLocked(update{Name: target.Name, Addr: target.Addr, Weight: target.Weight, Incarnation: target.Incarnation, State: StateSuspect})
+}
+
+// wait reports whether acked is closed within d. It also returns true once the member is closed,
+// so that a stopping member suspects no one.
+func (m *Memberlist) wait(acked <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-acked:
+		return true
+	case <-m.stop:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// nextTargetLocked returns the next member to probe. Members are probed in a random order
+// renewed every round, so that every member is probed within a bounded time.
+// It must be called with m.mu held.
+func (m *Memberlist) nextTargetLocked() (Member, bool) {
+	for {
+		if len(m.probes) == 0 {
+			for name, member := range m.members {
+				if member.active() {
+					m.probes = append(m.probes, name)
+				}
+			}
+			if len(m.probes) == 0 {
+				return Member{}, false
+			}
+			rand.Shuffle(len(m.probes), func(i, j int) { m.probes[i], m.probes[j] = m.probes[j], m.probes[i] })
+		}
+		name := m.probes[0]
+		m.probes = m.probes[1:]
+		if member := m.members[name]; member != nil && member.active() {
+			return *member, true
+		}
+	}
+}
+
+// randomMembers returns up to k random active members other than the named one.
+func (m *Memberlist) randomMembers(k int, exclude string) []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var members []Member
+	for name, member := range m.members {
+		if name != exclude && member.State == StateAlive {
+			members = append(members, *member)
+		}
+	}
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	return members[:min(k, len(members))]
+}
+
+// expectAck registers fn to run when the ack for seq arrives within timeout.
+func (m *Memberlist) expectAck(seq uint64, timeout time.Duration, fn func()) {
+	m.mu.Lock()
+	m.acks[seq] = fn
+	m.mu.Unlock()
+	time.AfterFunc(timeout, func() {
+		m.mu.Lock()
+		delete(m.acks, seq)
+		m.mu.Unlock()
+	})
+}
+
+// ack runs the handler registered for seq, if it is still awaited.
+func (m *Memberlist) ack(seq uint64) {
+	m.mu.Lock()
+	fn := m.acks[seq]
+	delete(m.acks, seq)
+	m.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// sendTo sends msg to the UDP address addr, piggybacking pending updates.
+// Failures are logged: the protocol tolerates lost datagrams.
+func (m *Memberlist) sendTo(addr string, msg *message) {
+	if m.cfg.dropTo != nil && m.cfg.dropTo(addr) {
+		return
+	}
+	m.mu.Lock()
+	msg.Updates = append(msg.Updates, m.queue.take(m.sizeLocked())...)
+	m.mu.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err == nil && len(data) > maxPacketSize {
+		err = fmt.Errorf("message of %d bytes is too large", len(data))
+	}
+	var udpAddr *net.UDPAddr
+	if err == nil {
+		udpAddr, err = net.ResolveUDPAddr("udp", addr)
+	}
+	if err == nil {
+		_, err = m.conn.WriteToUDP(data, udpAddr)
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		m.logf("send to %s failed: %v", addr, err)
+	}
+}
+
+// sizeLocked returns the number of active members, this node included.
+// It must be called with m.mu held.
+func (m *Memberlist) sizeLocked() int {
+	n := 1
+	for _, member := range m.members {
+		if member.active() {
+			n++
+		}
+	}
+	return n
+}
+
+// logf logs a message prefixed with the member name.
+func (m *Memberlist) logf(format string, v ...interface{}) {
+	log.Printf("[gossip %s] %s", m.cfg.Name, fmt.Sprintf(format, v...))
+}
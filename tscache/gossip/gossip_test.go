@@ -0,0 +1,226 @@
+package gossip
+
+import (
+	"sync"
+	"testing"
+	"time"
+	"tscache"
+	"tscache/consistenthash"
+)
+
+var _ Pool = (*tscache.HTTPPool)(nil)
+
+// fakePool records the peers reported by a member.
+type fakePool struct {
+	mu    sync.Mutex
+	peers map[string]bool
+}
+
+func (p *fakePool) AddPeers(nodes ...*consistenthash.Node) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, node := range nodes {
+		p.peers[node.Name] = true
+	}
+}
+
+func (p *fakePool) RemovePeers(names ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range names {
+		delete(p.peers, name)
+	}
+}
+
+func (p *fakePool) has(names ...string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.peers) != len(names) {
+		return false
+	}
+	for _, name := range names {
+		if !p.peers[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// testNode is a member started by a test with its pool.
+type testNode struct {
+	*Memberlist
+	pool *fakePool
+}
+
+// startNode starts a member on an ephemeral localhost port with fast timings.
+func startNode(t *testing.T, name string, dropTo func(addr string) bool) *testNode {
+	t.Helper()
+	pool := &fakePool{peers: make(map[string]bool)}
+	m, err := New(Config{
+		Name:             name,
+		BindAddr:         "127.0.0.1:0",
+		Pool:             pool,
+		ProbeInterval:    40 * time.Millisecond,
+		ProbeTimeout:     15 * time.Millisecond,
+		SuspicionTimeout: 200 * time.Millisecond,
+		SyncInterval:     200 * time.Millisecond,
+		dropTo:           dropTo,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return &testNode{Memberlist: m, pool: pool}
+}
+
+// startCluster starts n members joined through the first one.
+func startCluster(t *testing.T, names ...string) []*testNode {
+	t.Helper()
+	nodes := make([]*testNode, len(names))
+	for i, name := range names {
+		nodes[i] = startNode(t, name, nil)
+		if i > 0 {
+			if _, err := nodes[i].Join(nodes[0].LocalAddr()); err != nil {
+				t.Fatalf("join failed: %v", err)
+			}
+		}
+	}
+	return nodes
+}
+
+// waitFor polls cond until it holds or fails the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// state returns the state of the named member as seen by m.
+func state(m *Memberlist, name string) (Member, bool) {
+	for _, member := range m.Members() {
+		if member.Name == name {
+			return member, true
+		}
+	}
+	return Member{}, false
+}
+
+// TestJoin tests that every member learns about every other one through gossip.
+func TestJoin(t *testing.T) {
+	nodes := startCluster(t, "a", "b", "c", "d")
+	for _, n := range nodes {
+		waitFor(t, n.cfg.Name+" to see the whole cluster", func() bool {
+			return n.pool.has("a", "b", "c", "d")
+		})
+	}
+}
+
+// TestJoinUnreachable tests that joining only unreachable members fails.
+func TestJoinUnreachable(t *testing.T) {
+	a := startNode(t, "a", nil)
+	b := startNode(t, "b", nil)
+	addr := b.LocalAddr()
+	b.Close()
+	if n, err := a.Join(addr); err == nil || n != 0 {
+		t.Errorf("expected the join to fail, got %d, %v", n, err)
+	}
+}
+
+// TestFailureDetection tests that a crashed member is suspected, then removed everywhere.
+func TestFailureDetection(t *testing.T) {
+	nodes := startCluster(t, "a", "b", "c")
+	for _, n := range nodes {
+		waitFor(t, "the cluster to form", func() bool { return n.pool.has("a", "b", "c") })
+	}
+
+	nodes[2].Close()
+	for _, n := range nodes[:2] {
+		waitFor(t, n.cfg.Name+" to remove c", func() bool { return n.pool.has("a", "b") })
+		if member, _ := state(n.Memberlist, "c"); member.State != StateDead {
+			t.Errorf("expected c to be dead for %s, got %v", n.cfg.Name, member.State)
+		}
+	}
+}
+
+// TestLeave tests that a member leaving is removed without waiting for the failure detector.
+func TestLeave(t *testing.T) {
+	nodes := startCluster(t, "a", "b", "c")
+	for _, n := range nodes {
+		waitFor(t, "the cluster to form", func() bool { return n.pool.has("a", "b", "c") })
+	}
+
+	if err := nodes[2].Leave(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, n := range nodes[:2] {
+		waitFor(t, n.cfg.Name+" to remove c", func() bool { return n.pool.has("a", "b") })
+		if member, _ := state(n.Memberlist, "c"); member.State != StateLeft {
+			t.Errorf("expected c to have left for %s, got %v", n.cfg.Name, member.State)
+		}
+	}
+}
+
+// TestRefute tests that a member suspected by mistake refutes with a higher incarnation.
+func TestRefute(t *testing.T) {
+	nodes := startCluster(t, "a", "b")
+	waitFor(t, "the cluster to form", func() bool { return nodes[0].pool.has("a", "b") })
+
+	// a suspects b although b is fine
+	a := nodes[0].Memberlist
+	a.mu.Lock()
+	a.applyLocked(update{Name: "b", Addr: nodes[1].LocalAddr(), State: StateSuspect})
+	a.mu.Unlock()
+
+	waitFor(t, "b to refute", func() bool {
+		member, _ := state(a, "b")
+		return member.State == StateAlive && member.Incarnation == 1
+	})
+	time.Sleep(300 * time.Millisecond)
+	if !nodes[0].pool.has("a", "b") {
+		t.Error("expected b to stay in the pool")
+	}
+}
+
+// TestIndirectProbe tests that a member unreachable from one node, but reachable from the
+// others, is not suspected thanks to ping-req.
+func TestIndirectProbe(t *testing.T) {
+	c := startNode(t, "c", nil)
+	b := startNode(t, "b", nil)
+	a := startNode(t, "a", func(addr string) bool { return addr == c.LocalAddr() })
+	if _, err := b.Join(c.LocalAddr()); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	if _, err := a.Join(b.LocalAddr()); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	waitFor(t, "a to see the whole cluster", func() bool { return a.pool.has("a", "b", "c") })
+
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if member, _ := state(a.Memberlist, "c"); member.State != StateAlive {
+			t.Fatalf("expected c to stay alive, got %v", member.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestRestart tests that a member restarted after being declared dead joins again.
+func TestRestart(t *testing.T) {
+	nodes := startCluster(t, "a", "b")
+	waitFor(t, "the cluster to form", func() bool { return nodes[0].pool.has("a", "b") })
+
+	nodes[1].Close()
+	waitFor(t, "a to remove b", func() bool { return nodes[0].pool.has("a") })
+
+	b := startNode(t, "b", nil)
+	if _, err := b.Join(nodes[0].LocalAddr()); err != nil {
+		t.Fatalf("join failed: %v", err)
+	}
+	waitFor(t, "a to add b again", func() bool { return nodes[0].pool.has("a", "b") })
+	waitFor(t, "b to see a", func() bool { return b.pool.has("a", "b") })
+}
@@ -0,0 +1,76 @@
+package gossip
+
+// State is the state of a member as known by the cluster.
+type State int
+
+const (
+	// StateAlive is a member answering probes.
+	StateAlive State = iota
+	// StateSuspect is a member that missed a probe; it is declared dead unless it refutes in time.
+	StateSuspect
+	// StateDead is a member that stayed suspect for the whole suspicion timeout.
+	StateDead
+	// StateLeft is a member that left the cluster on its own.
+	StateLeft
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateAlive:
+		return "alive"
+	case StateSuspect:
+		return "suspect"
+	case StateDead:
+		return "dead"
+	case StateLeft:
+		return "left"
+	default:
+		return "unknown"
+	}
+}
+
+// Member is a node of the cluster.
+type Member struct {
+	Name        string // Name is the peer address used by the cache, e.g. "http://10.0.0.1:8001".
+	Addr        string // Addr is the UDP address the member gossips on.
+	Weight      int    // Weight is the relative capacity of the member on the hash ring.
+	Incarnation uint64 // Incarnation is raised by the member to refute suspicions about itself.
+	State       State  // State is the state of the member.
+}
+
+// active reports whether the member belongs to the peer ring.
+func (m *Member) active() bool {
+	return m.State == StateAlive || m.State == StateSuspect
+}
+
+// update is a piece of membership news gossiped between members.
+type update struct {
+	Name        string `json:"n"`
+	Addr        string `json:"a,omitempty"`
+	Weight      int    `json:"w,omitempty"`
+	Incarnation uint64 `json:"i"`
+	State       State  `json:"s"`
+}
+
+// toUpdate returns the news announcing the member's current state.
+func (m *Member) toUpdate() update {
+	return update{Name: m.Name, Addr: m.Addr, Weight: m.Weight, Incarnation: m.Incarnation, State: m.State}
+}
+
+// overrides reports whether u supersedes what is known about cur, following SWIM's rules:
+// a higher incarnation always wins, and at equal incarnations dead or left beats suspect,
+// which beats alive.
+func (u *update) overrides(cur *Member) bool {
+	switch u.State {
+	case StateAlive:
+		return u.Incarnation > cur.Incarnation
+	case StateSuspect:
+		if cur.State == StateAlive {
+			return u.Incarnation >= cur.Incarnation
+		}
+		return cur.State == StateSuspect && u.Incarnation > cur.Incarnation
+	default:
+		return cur.active() && u.Incarnation >= cur.Incarnation
+	}
+}
@@ -0,0 +1,79 @@
+package gossip
+
+import (
+	"math"
+	"sort"
+)
+
+// maxPiggyback is the number of updates carried by a single message.
+const maxPiggyback = 8
+
+// msgType identifies a message of the protocol.
+type msgType int
+
+const (
+	msgPing    msgType = iota // Asks the target for an ack
+	msgPingReq                // Asks a member to ping the target on the sender's behalf
+	msgAck                    // Answers a ping, possibly relayed
+	msgJoin                   // Sends the whole membership and asks for the receiver's
+	msgSync                   // Answers a join with the whole membership
+	msgGossip                 // Carries updates only
+)
+
+// message is a UDP datagram of the protocol, encoded as JSON.
+type message struct {
+	Type       msgType  `json:"t"`
+	Seq        uint64   `json:"q,omitempty"`
+	Target     string   `json:"g,omitempty"`  // Name of the member to ping
+	TargetAddr string   `json:"ga,omitempty"` // Address of the member to ping, for ping-req
+	Updates    []update `json:"u,omitempty"`  // Piggybacked updates
+	Members    []update `json:"m,omitempty"`  // Whole membership, for join and sync
+}
+
+// broadcast is an update waiting to be piggybacked.
+type broadcast struct {
+	update    update
+	transmits int // Number of messages that carried the update
+}
+
+// broadcasts is the queue of updates to gossip; an update is sent a number of times that
+// grows with the logarithm of the cluster size, so that it reaches every member with high probability.
+type broadcasts struct {
+	queue []*broadcast
+	mult  int // Retransmission multiplier
+}
+
+// add queues u, replacing any older update about the same member.
+func (b *broadcasts) add(u update) {
+	for i, q := range b.queue {
+		if q.update.Name == u.Name {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			break
+		}
+	}
+	b.queue = append(b.queue, &broadcast{update: u})
+}
+
+// take returns the updates to piggyback on the next message in a cluster of n members.
+// The least transmitted updates go first, and updates sent often enough are dropped.
+func (b *broadcasts) take(n int) []update {
+	if len(b.queue) == 0 {
+		return nil
+	}
+	limit := b.mult * max(1, int(math.Ceil(math.Log10(float64(n+1)))))
+	sort.SliceStable(b.queue, func(i, j int) bool { return b.queue[i].transmits < b.queue[j].transmits })
+
+	var updates []update
+	kept := b.queue[:0]
+	for i, q := range b.queue {
+		if i < maxPiggyback {
+			updates = append(updates, q.update)
+			q.transmits++
+		}
+		if q.transmits < limit {
+			kept = append(kept, q)
+		}
+	}
+	b.queue = kept
+	return updates
+}
@@ -0,0 +1,242 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"tscache/consistenthash"
+	pb "tscache/tscachepb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// grpcGetter implements the PeerGetter interface over a gRPC connection to a remote peer.
+type grpcGetter struct {
+	addr   string              // addr is the address of the remote peer.
+	conn   *grpc.ClientConn    // conn is the connection to the remote peer, shared by all requests.
+	client pb.GroupCacheClient // client is the GroupCache client bound to conn.
+}
+
+// Get calls the Get RPC on the remote peer. The context's deadline is carried by gRPC.
+func (g *grpcGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	res, err := g.client.Get(ctx, in)
+	if err != nil {
+		return err
+	}
+	out.Value = res.GetValue()
+	out.Expire = res.GetExpire()
+	out.Compression = res.GetCompression()
+	out.NotFound = res.GetNotFound()
+	return nil
+}
+
+// GetMany calls the GetMany RPC on the remote peer.
+func (g *grpcGetter) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	res, err := g.client.GetMany(ctx, in)
+	if err != nil {
+		return err
+	}
+	out.Values = res.GetValues()
+	return nil
+}
+
+// Remove calls the Remove RPC on the remote peer.
+func (g *grpcGetter) Remove(ctx context.Context, in *pb.Request) error {
+	_, err := g.client.Remove(ctx, in)
+	return err
+}
+
+// GRPCPool implements PeerPicker over gRPC and serves the GroupCache service for other peers.
+type GRPCPool struct {
+	pb.UnimplementedGroupCacheServer
+
+	poolOptions
+
+	self        string                 // self represents the address of this GRPCPool instance.
+	mu          sync.Mutex             // mu is used to synchronize access to the GRPCPool instance.
+	peers       Placer                 // peers maps keys to cache peers.
+	nodes       []*consistenthash.Node // nodes is the current membership.
+	grpcGetters map[string]*grpcGetter // grpcGetters is a map of gRPC getters for each cache peer.
+}
+
+// WithDialOptions sets the options a GRPCPool uses when connecting to peers. Without them, peers
+// are reached over plaintext connections. HTTPPool ignores them.
+func WithDialOptions(opts ...grpc.DialOption) PoolOption {
+	return func(o *poolOptions) {
+		o.dialOpts = opts
+	}
+}
+
+// NewGRPCPool creates and returns a new GRPCPool instance with the specified address.
+func NewGRPCPool(self string, opts ...PoolOption) *GRPCPool {
+	p := &GRPCPool{
+		poolOptions: newPoolOptions(opts),
+		self:        self,
+		grpcGetters: make(map[string]*grpcGetter),
+	}
+	if len(p.dialOpts) == 0 {
+		p.dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return p
+}
+
+// Log prints a formatted log message prefixed with the server's address.
+func (p *GRPCPool) Log(format string, v ...interface{}) {
+	log.Printf("[gRPC Server %s] %s", p.self, fmt.Sprintf(format, v...))
+}
+
+// Registry returns the registry whose groups the pool serves.
+func (p *GRPCPool) Registry() *Registry {
+	return p.registry
+}
+
+// Register registers the GroupCache service of this pool on a gRPC server.
+func (p *GRPCPool) Register(s *grpc.Server) {
+	pb.RegisterGroupCacheServer(s, p)
+}
+
+// Set sets the list of cache peers in the GRPCPool.
+// Connections to peers that remain in the list are reused; connections to dropped peers are closed.
+// If a connection cannot be created, the peers are left unchanged.
+func (p *GRPCPool) Set(nodes ...*consistenthash.Node) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	getters := make(map[string]*grpcGetter, len(nodes))
+	var opened []*grpc.ClientConn
+	for _, node := range nodes {
+		if node.Name == p.self {
+			continue
+		}
+		if getter, ok := p.grpcGetters[node.Name]; ok {
+			getters[node.Name] = getter
+			continue
+		}
+		conn, err := grpc.NewClient(node.Name, p.dialOpts...)
+		if err != nil {
+			for _, conn := range opened {
+				conn.Close()
+			}
+			return fmt.Errorf("connecting to peer %s: %v", node.Name, err)
+		}
+		opened = append(opened, conn)
+		getters[node.Name] = &grpcGetter{addr: node.Name, conn: conn, client: pb.NewGroupCacheClient(conn)}
+	}
+	for name, getter := range p.grpcGetters {
+		if _, ok := getters[name]; !ok {
+			getter.conn.Close()
+		}
+	}
+	p.peers = p.newPlacer()
+	p.peers.Add(nodes...)
+	p.nodes = nodes
+	p.grpcGetters = getters
+	return nil
+}
+
+// PickPeer selects a cache peer for a given key using consistent hashing.
+// It returns the selected PeerGetter and a boolean indicating whether a peer was found.
+func (p *GRPCPool) PickPeer(key string) (PeerGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
+	if peer, _ := p.peers.SelectNode(key); peer != nil && peer.Name != p.self {
+		p.Log("Pick peer %s", peer.Name)
+		return p.grpcGetters[peer.Name], true
+	}
+	return nil, false
+}
+
+// Ring returns the members of the pool and their placement.
+func (p *GRPCPool) Ring() Ring {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return newRing(p.self, nil, nil, nil)
+	}
+	return newRing(p.self, p.nodes, p.peers, nil)
+}
+
+// Owner returns the name of the node responsible for key, which may be this node.
+func (p *GRPCPool) Owner(key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return "", errors.New("no peers")
+	}
+	node, err := p.peers.SelectNode(key)
+	if err != nil {
+		return "", err
+	}
+	return node.Name, nil
+}
+
+// GetAll returns the gRPC getters of every peer except this GRPCPool instance.
+func (p *GRPCPool) GetAll() []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peers := make([]PeerGetter, 0, len(p.grpcGetters))
+	for _, getter := range p.grpcGetters {
+		peers = append(peers, getter)
+	}
+	return peers
+}
+
+// Close closes the connections to all peers.
+func (p *GRPCPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errList []error
+	for name, getter := range p.grpcGetters {
+		if err := getter.conn.Close(); err != nil {
+			errList = append(errList, err)
+		}
+		delete(p.grpcGetters, name)
+	}
+	return errors.Join(errList...)
+}
+
+// Get serves the Get RPC for other peers.
+func (p *GRPCPool) Get(ctx context.Context, in *pb.Request) (*pb.Response, error) {
+	group := p.registry.Get(in.GetGroup())
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group:%s", in.GetGroup())
+	}
+
+	group.stats.serverRequests.Add(1)
+	byteView, err := group.get(ctx, in.GetKey(), !in.GetForwarded())
+	if errors.Is(err, ErrNotFound) {
+		return &pb.Response{NotFound: true}, nil
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "Get value failed:%s", in.GetKey())
+	}
+	return newResponse(byteView, group.codec, in.GetAcceptCompression()), nil
+}
+
+// GetMany serves the GetMany RPC for other peers.
+func (p *GRPCPool) GetMany(ctx context.Context, in *pb.BatchRequest) (*pb.BatchResponse, error) {
+	group := p.registry.Get(in.GetGroup())
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group:%s", in.GetGroup())
+	}
+
+	return group.batchResponse(ctx, in), nil
+}
+
+// Remove serves the Remove RPC for other peers by dropping the key from this node's caches.
+func (p *GRPCPool) Remove(ctx context.Context, in *pb.Request) (*pb.Response, error) {
+	group := p.registry.Get(in.GetGroup())
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "no such group:%s", in.GetGroup())
+	}
+
+	group.localRemove(in.GetKey())
+	return &pb.Response{}, nil
+}
@@ -0,0 +1,178 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+	"tscache/consistenthash"
+	pb "tscache/tscachepb"
+
+	"google.golang.org/grpc"
+)
+
+// startGRPCServer serves a GRPCPool on an ephemeral local port and returns its address.
+func startGRPCServer(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	server := grpc.NewServer()
+	NewGRPCPool(lis.Addr().String()).Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+// TestGRPCPool tests fetching and removing values through a gRPC peer.
+func TestGRPCPool(t *testing.T) {
+	loads := 0
+	NewGroupContext("grpc-group", 100, GetterCtxFunc(func(ctx context.Context, key string) ([]byte, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("missing deadline")
+		}
+		loads++
+		return []byte("value-" + key), nil
+	}))
+	addr := startGRPCServer(t)
+
+	client := NewGRPCPool("client")
+	defer client.Close()
+	if err := client.Set(&consistenthash.Node{Name: addr}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	peer, ok := client.PickPeer("key1")
+	if !ok {
+		t.Fatal("expected the remote node to own key1")
+	}
+	if all := client.GetAll(); len(all) != 1 || all[0] != peer {
+		t.Fatalf("expected GetAll to return the single peer, got %v", all)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	view, err := (&Group{name: "grpc-group"}).getFromPeer(ctx, peer, "key1")
+	if err != nil || view.String() != "value-key1" {
+		t.Fatalf("unexpected result %q, %v", view, err)
+	}
+
+	if err := peer.Remove(ctx, &pb.Request{Group: "grpc-group", Key: "key1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := (&Group{name: "grpc-group"}).getFromPeer(ctx, peer, "key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("expected 2 loads around the removal, got %d", loads)
+	}
+}
+
+// TestGRPCPool_SetReusesConnections tests that Set keeps connections to peers that stay in the list.
+func TestGRPCPool_SetReusesConnections(t *testing.T) {
+	pool := NewGRPCPool("self")
+	defer pool.Close()
+
+	pool.Set(&consistenthash.Node{Name: "self"}, &consistenthash.Node{Name: "127.0.0.1:1"})
+	first := pool.grpcGetters["127.0.0.1:1"]
+	pool.Set(&consistenthash.Node{Name: "self"}, &consistenthash.Node{Name: "127.0.0.1:1"}, &consistenthash.Node{Name: "127.0.0.1:2"})
+
+	if pool.grpcGetters["127.0.0.1:1"] != first {
+		t.Error("expected the connection to an existing peer to be reused")
+	}
+	if _, ok := pool.grpcGetters["self"]; ok {
+		t.Error("expected no connection to self")
+	}
+	if len(pool.GetAll()) != 2 {
+		t.Errorf("expected 2 peers, got %d", len(pool.GetAll()))
+	}
+}
+
+// TestGRPCPool_SetError tests that a peer that cannot be connected to leaves the peers unchanged.
+func TestGRPCPool_SetError(t *testing.T) {
+	pool := NewGRPCPool("self")
+	defer pool.Close()
+
+	pool.Set(&consistenthash.Node{Name: "self"}, &consistenthash.Node{Name: "127.0.0.1:1"})
+	err := pool.Set(&consistenthash.Node{Name: "self"}, &consistenthash.Node{Name: "127.0.0.1:1"},
+		&consistenthash.Node{Name: "127.0.0.1:2"}, &consistenthash.Node{Name: "%zz"})
+	if err == nil {
+		t.Fatal("expected an error for an invalid peer address")
+	}
+	if len(pool.grpcGetters) != 1 || pool.grpcGetters["127.0.0.1:1"] == nil {
+		t.Errorf("expected the previous peers to be kept, got %v", pool.grpcGetters)
+	}
+}
+
+// TestGRPCPool_Options tests that a gRPC pool takes the registry and placement options of HTTPPool.
+func TestGRPCPool_Options(t *testing.T) {
+	registry := NewRegistry()
+	placement := consistenthash.NewRendezvous(nil)
+	pool := NewGRPCPool("self", WithRegistry(registry), WithPlacer(func() Placer { return placement }))
+	defer pool.Close()
+	if pool.Registry() != registry {
+		t.Error("expected the pool to serve the given registry")
+	}
+
+	pool.Set(&consistenthash.Node{Name: "self"}, &consistenthash.Node{Name: "127.0.0.1:1"})
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		owner, _ := placement.SelectNode(key)
+		if _, ok := pool.PickPeer(key); ok != (owner.Name == "127.0.0.1:1") {
+			t.Fatalf("key %s: placement picked %s but the pool did not follow it", key, owner.Name)
+		}
+	}
+}
+
+// TestGRPCPool_NotFound tests that a missing key is reported as such over gRPC.
+func TestGRPCPool_NotFound(t *testing.T) {
+	NewGroup("grpc-not-found", 100, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("no row for %s: %w", key, ErrNotFound)
+	}))
+	addr := startGRPCServer(t)
+
+	client := NewGRPCPool("client")
+	defer client.Close()
+	if err := client.Set(&consistenthash.Node{Name: addr}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	peer, _ := client.PickPeer("missing")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := (&Group{name: "grpc-not-found"}).getFromPeer(ctx, peer, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGRPCPool_GetMany(t *testing.T) {
+	NewGroup("grpc-batch", 1<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "missing" {
+			return nil, ErrNotFound
+		}
+		return []byte("value-" + key), nil
+	}))
+	addr := startGRPCServer(t)
+
+	client := NewGRPCPool("client")
+	defer client.Close()
+	if err := client.Set(&consistenthash.Node{Name: addr}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	caller := &Group{name: "grpc-batch", mainCache: &cache{}}
+	caller.RegisterNodes(client)
+	values, err := caller.GetMany(ctx, []string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 2 || values["b"].String() != "value-b" {
+		t.Errorf("unexpected values %v", values)
+	}
+}
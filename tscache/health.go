@@ -0,0 +1,172 @@
+package tscache
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+const (
+	// healthPath is the endpoint, relative to the base path, that reports a node as alive.
+	healthPath = "_health"
+
+	defaultHealthInterval   = 5 * time.Second
+	defaultHealthTimeout    = time.Second
+	defaultFailureThreshold = 3
+)
+
+// HealthOptions configures the health checking of an HTTPPool's peers.
+type HealthOptions struct {
+	Interval         time.Duration // Interval is the time between two active probes of every peer.
+	Timeout          time.Duration // Timeout bounds a single probe.
+	FailureThreshold int           // FailureThreshold is the number of consecutive failures that ejects a peer.
+}
+
+// StartHealthChecks probes every peer's health endpoint until ctx is done.
+// While it runs, requests to peers are tracked too: a peer failing FailureThreshold times in a row,
+// whether probed or used, is removed from PickPeer routing until a probe succeeds again.
+// Starting health checks again replaces the running ones. Once they stop, ejected peers are
+// readmitted, since nothing would readmit them anymore.
+func (p *HTTPPool) StartHealthChecks(ctx context.Context, opts HealthOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultHealthInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultHealthTimeout
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultFailureThreshold
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	health := &opts
+	p.mu.Lock()
+	if p.stopHealth != nil {
+		p.stopHealth()
+	}
+	p.health, p.stopHealth = health, cancel
+	p.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				p.stopHealthChecks(health)
+				return
+			case <-ticker.C:
+				p.probeAll(ctx, opts.Timeout)
+			}
+		}
+	}()
+}
+
+// stopHealthChecks ends the health checks configured with health, unless others replaced them,
+// and readmits the ejected peers.
+func (p *HTTPPool) stopHealthChecks(health *HealthOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.health != health {
+		return
+	}
+	p.stopHealth()
+	p.health, p.stopHealth = nil, nil
+	for name := range p.ejected {
+		if node, ok := p.nodes[name]; ok {
+			p.peers.Add(node)
+			p.Log("Peer %s readmitted as health checks stopped", name)
+		}
+	}
+	p.failures, p.ejected = nil, nil
+}
+
+// Healthy reports whether the named peer currently receives keys.
+func (p *HTTPPool) Healthy(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, member := p.nodes[name]
+	return member && !p.ejected[name]
+}
+
+// newGetter creates the HTTP getter of a peer, wired to passive health tracking and load reporting.
+func (p *HTTPPool) newGetter(name string) *httpGetter {
+	return &httpGetter{
+		baseURL: name + p.basePath,
+		report: func(ok bool) {
+			p.record(name, ok)
+		},
+		begin: func() func() {
+			return p.beginLoad(name)
+		},
+	}
+}
+
+// probeAll probes the health endpoint of every remote member concurrently.
+func (p *HTTPPool) probeAll(ctx context.Context, timeout time.Duration) {
+	p.mu.Lock()
+	names := make([]string, 0, len(p.nodes))
+	for name := range p.nodes {
+		if name != p.self {
+			names = append(names, name)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, name := range names {
+		go func(name string) {
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			ok := p.probe(probeCtx, name)
+			if ctx.Err() == nil {
+				p.record(name, ok)
+			}
+		}(name)
+	}
+}
+
+// probe requests the health endpoint of the named peer.
+func (p *HTTPPool) probe(ctx context.Context, name string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, name+p.basePath+healthPath, nil)
+	if err != nil {
+		return false
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return res.StatusCode == http.StatusOK
+}
+
+// record updates the health of the named peer with the outcome of a request or probe.
+// A success readmits an ejected peer; FailureThreshold consecutive failures eject it.
+func (p *HTTPPool) record(name string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	node, member := p.nodes[name]
+	if p.health == nil || !member || name == p.self {
+		return
+	}
+	if p.failures == nil {
+		p.failures = make(map[string]int)
+		p.ejected = make(map[string]bool)
+	}
+
+	if ok {
+		delete(p.failures, name)
+		if p.ejected[name] {
+			delete(p.ejected, name)
+			p.peers.Add(node)
+			p.Log("Peer %s recovered", name)
+		}
+		return
+	}
+
+	p.failures[name]++
+	if p.failures[name] >= p.health.FailureThreshold && !p.ejected[name] {
+		p.ejected[name] = true
+		p.peers.Remove(node)
+		p.Log("Peer %s ejected after %d failures", name, p.failures[name])
+	}
+}
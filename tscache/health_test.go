@@ -0,0 +1,134 @@
+package tscache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tscache/consistenthash"
+	pb "tscache/tscachepb"
+)
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestHTTPPool_HealthEndpoint tests that a pool answers its health endpoint.
+func TestHTTPPool_HealthEndpoint(t *testing.T) {
+	server := httptest.NewServer(NewHTTPPool("owner"))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + defaultBasePath + healthPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %v", res.Status)
+	}
+}
+
+// TestHTTPPool_ActiveHealthCheck tests that a peer failing its probes is ejected and readmitted once it recovers.
+func TestHTTPPool_ActiveHealthCheck(t *testing.T) {
+	var down atomic.Bool
+	peerPool := NewHTTPPool("peer")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		peerPool.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	pool := NewHTTPPool("http://self")
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: server.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.StartHealthChecks(ctx, HealthOptions{Interval: 10 * time.Millisecond, FailureThreshold: 2})
+
+	down.Store(true)
+	waitFor(t, func() bool { return !pool.Healthy(server.URL) })
+	for i := 0; i < 100; i++ {
+		if _, ok := pool.PickPeer(strconv.Itoa(i)); ok {
+			t.Fatalf("key %d routed to an ejected peer", i)
+		}
+	}
+
+	down.Store(false)
+	waitFor(t, func() bool { return pool.Healthy(server.URL) })
+	routed := false
+	for i := 0; i < 100 && !routed; i++ {
+		_, routed = pool.PickPeer(strconv.Itoa(i))
+	}
+	if !routed {
+		t.Fatal("expected keys to be routed to the recovered peer")
+	}
+}
+
+// TestHTTPPool_PassiveHealthCheck tests that failed peer requests eject the peer.
+func TestHTTPPool_PassiveHealthCheck(t *testing.T) {
+	server := httptest.NewServer(NewHTTPPool("peer"))
+	addr := server.URL
+	server.Close()
+
+	pool := NewHTTPPool("http://self")
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: addr})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.StartHealthChecks(ctx, HealthOptions{Interval: time.Hour, FailureThreshold: 3})
+
+	getter := pool.GetAll()[0]
+	for i := 0; i < 3; i++ {
+		if err := getter.Get(ctx, &pb.Request{Group: "g", Key: "k"}, &pb.Response{}); err == nil {
+			t.Fatal("expected an error from a closed peer")
+		}
+	}
+	if pool.Healthy(addr) {
+		t.Fatal("expected the peer to be ejected after 3 failures")
+	}
+}
+
+// TestHTTPPool_StopHealthChecks tests that stopping health checks readmits ejected peers, and
+// that a second checker replaces the first one.
+func TestHTTPPool_StopHealthChecks(t *testing.T) {
+	server := httptest.NewServer(NewHTTPPool("peer"))
+	addr := server.URL
+	server.Close()
+
+	pool := NewHTTPPool("http://self")
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: addr})
+	first, cancelFirst := context.WithCancel(context.Background())
+	defer cancelFirst()
+	pool.StartHealthChecks(first, HealthOptions{Interval: 10 * time.Millisecond, FailureThreshold: 1})
+	waitFor(t, func() bool { return !pool.Healthy(addr) })
+
+	second, cancelSecond := context.WithCancel(context.Background())
+	pool.StartHealthChecks(second, HealthOptions{Interval: 10 * time.Millisecond, FailureThreshold: 1})
+	// The replaced checker stops without undoing the health state of the running one
+	time.Sleep(30 * time.Millisecond)
+	if pool.Healthy(addr) {
+		t.Fatal("expected the peer to stay ejected while the second checker runs")
+	}
+
+	cancelSecond()
+	waitFor(t, func() bool { return pool.Healthy(addr) })
+	routed := false
+	for i := 0; i < 100 && !routed; i++ {
+		_, routed = pool.PickPeer(strconv.Itoa(i))
+	}
+	if !routed {
+		t.Error("expected keys to be routed to the readmitted peer")
+	}
+}
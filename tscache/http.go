@@ -1,13 +1,19 @@
 package tscache
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"tscache/consistenthash"
 	pb "tscache/tscachepb"
 
@@ -17,31 +23,68 @@ import (
 const (
 	defaultBasePath = "/_tscache/"
 	defaultReplicas = 50
+
+	// timeoutHeader carries the caller's remaining deadline, in milliseconds, to the peer.
+	timeoutHeader = "X-Tscache-Timeout"
+
+	// forwardedHeader marks a request sent by a peer that routed the key here, as the forwarded
+	// field of a Request message does.
+	forwardedHeader = "X-Tscache-Forwarded"
 )
 
+// AcceptCompressionHeader lists, comma-separated, the codecs the caller of HTTPPool can decode.
+// Values are sent compressed with the group's codec only to callers that list it.
+const AcceptCompressionHeader = "X-Tscache-Accept-Compression"
+
 // httpGetter implements the PeerGetter interface and is responsible for making HTTP GET requests to fetch data from remote peers.
 type httpGetter struct {
-	baseURL string // baseURL is the base URL for making HTTP GET requests.
+	baseURL string        // baseURL is the base URL for making HTTP GET requests.
+	report  func(ok bool) // report, if set, receives the outcome of every request for passive health tracking.
+	begin   func() func() // begin, if set, reports the start of a fetch and returns the function reporting its end.
 }
 
 // Get performs an HTTP GET request to fetch the data associated with a key from a remote peer.
 // It takes a Request message as input and populates the Response message with the fetched data.
-func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
-	u := fmt.Sprintf(
-		"%v%v/%v",
-		h.baseURL,
-		url.QueryEscape(in.GetGroup()),
-		url.QueryEscape(in.GetKey()),
-	)
-	res, err := http.Get(u)
+func (h *httpGetter) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	if h.begin != nil {
+		defer h.begin()()
+	}
+	res, err := h.do(ctx, http.MethodGet, in)
+	if err != nil {
+		return err
+	}
+	return readResponse(res, out)
+}
+
+// GetMany performs an HTTP POST request to fetch the values of many keys from a remote peer.
+// The BatchRequest message is sent as the body and the BatchResponse message is read back.
+func (h *httpGetter) GetMany(ctx context.Context, in *pb.BatchRequest, out *pb.BatchResponse) error {
+	if h.begin != nil {
+		defer h.begin()()
+	}
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("encoding request body: %v", err)
+	}
+	u := fmt.Sprintf("%v%v/", h.baseURL, url.QueryEscape(in.GetGroup()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := h.send(ctx, req)
 	if err != nil {
 		return err
 	}
+	return readResponse(res, out)
+}
 
+// readResponse decodes the message in the body of a peer's response and closes the body.
+func readResponse(res *http.Response, out proto.Message) error {
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned:%v", res.Status)
+		return &statusError{code: res.StatusCode, status: res.Status}
 	}
 
 	bytes, err := io.ReadAll(res.Body)
@@ -56,20 +99,106 @@ func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
 	return nil
 }
 
+// Remove performs an HTTP DELETE request asking a remote peer to drop the key from its caches.
+func (h *httpGetter) Remove(ctx context.Context, in *pb.Request) error {
+	res, err := h.do(ctx, http.MethodDelete, in)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &statusError{code: res.StatusCode, status: res.Status}
+	}
+	return nil
+}
+
+// do sends a request with the given method for the group and key in the Request message.
+func (h *httpGetter) do(ctx context.Context, method string, in *pb.Request) (*http.Response, error) {
+	u := fmt.Sprintf(
+		"%v%v/%v",
+		h.baseURL,
+		url.QueryEscape(in.GetGroup()),
+		url.QueryEscape(in.GetKey()),
+	)
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept := in.GetAcceptCompression(); len(accept) > 0 {
+		req.Header.Set(AcceptCompressionHeader, strings.Join(accept, ","))
+	}
+	if in.GetForwarded() {
+		req.Header.Set(forwardedHeader, "1")
+	}
+	return h.send(ctx, req)
+}
+
+// send sends a request to the peer, forwarding the caller's deadline.
+func (h *httpGetter) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(timeoutHeader, strconv.FormatInt(time.Until(deadline).Milliseconds(), 10))
+	}
+	res, err := http.DefaultClient.Do(req)
+	h.observe(ctx, res, err)
+	return res, err
+}
+
+// observe reports whether a request reached a healthy peer.
+// Requests abandoned by the caller and server-side errors of the 5xx class are told apart.
+func (h *httpGetter) observe(ctx context.Context, res *http.Response, err error) {
+	if h.report == nil {
+		return
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			h.report(false)
+		}
+		return
+	}
+	h.report(res.StatusCode < http.StatusInternalServerError)
+}
+
+// statusError is returned when a peer answers with a status other than 200 OK.
+type statusError struct {
+	code   int    // code is the HTTP status code.
+	status string // status is the HTTP status line.
+}
+
+// Error returns the status returned by the peer.
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned:%v", e.status)
+}
+
 // HTTPPool implements the http.Handler interface and serves as the HTTP-based cache pool.
 type HTTPPool struct {
-	self        string                 // self represents the address of this HTTPPool instance.
-	basePath    string                 // basePath represents the base path for all cache-related HTTP endpoints.
-	mu          sync.Mutex             // mu is used to synchronize access to the HTTPPool instance.
-	peers       *consistenthash.Map    // peers is a consistent hash map of cache peers.
-	httpGetters map[string]*httpGetter // httpGetters is a map of HTTP getters for each cache peer.
+	poolOptions
+
+	self        string                          // self represents the address of this HTTPPool instance.
+	basePath    string                          // basePath represents the base path for all cache-related HTTP endpoints.
+	mu          sync.Mutex                      // mu is used to synchronize access to the HTTPPool instance.
+	peers       Placer                          // peers maps keys to cache peers.
+	httpGetters map[string]*httpGetter          // httpGetters is a map of HTTP getters for each cache peer.
+	nodes       map[string]*consistenthash.Node // nodes is the current membership, keyed by node name.
+
+	onChange func(MembershipChange) // onChange is called after the membership changes.
+
+	health     *HealthOptions     // health is set while health checks run; it enables passive tracking.
+	stopHealth context.CancelFunc // stopHealth stops the running health checks.
+	failures   map[string]int     // failures counts consecutive failed requests per peer.
+	ejected    map[string]bool    // ejected marks members temporarily removed from routing.
+
+	breakerOpts *BreakerOptions           // breakerOpts is set once circuit breakers are enabled.
+	breakers    map[string]*breakerGetter // breakers wraps the getter of each peer with its circuit breaker.
 }
 
 // NewHTTPPool creates and returns a new HTTPPool instance with the specified address.
-func NewHTTPPool(self string) *HTTPPool {
+func NewHTTPPool(self string, opts ...PoolOption) *HTTPPool {
 	return &HTTPPool{
-		self:     self,
-		basePath: defaultBasePath,
+		poolOptions: newPoolOptions(opts),
+		self:        self,
+		basePath:    defaultBasePath,
 	}
 }
 
@@ -79,11 +208,17 @@ func (p *HTTPPool) Log(format string, v ...interface{}) {
 }
 
 // ServeHTTP handles incoming HTTP requests and routes them to the appropriate cache group.
+// Paths outside the pool's base path are not found.
 func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !strings.HasPrefix(r.URL.Path, p.basePath) {
-		panic("Unexpected Path:" + r.URL.Path)
+		http.NotFound(w, r)
+		return
 	}
 	p.Log("%s %s", r.Method, r.URL.Path)
+	if r.URL.Path == p.basePath+healthPath {
+		w.Write([]byte("ok"))
+		return
+	}
 	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
 	if len(parts) != 2 {
 		http.Error(w, "bad request", http.StatusBadRequest)
@@ -93,19 +228,69 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	groupName := parts[0]
 	key := parts[1]
 
-	group := GetGroup(groupName)
+	group := p.registry.Get(groupName)
 	if group == nil {
 		http.Error(w, "no such group:"+groupName, http.StatusNotFound)
 		return
 	}
 
-	byteView, err := group.Get(key)
-	if err != nil {
+	if r.Method == http.MethodDelete {
+		group.localRemove(key)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	ctx, cancel := requestContext(r)
+	defer cancel()
+
+	if r.Method == http.MethodPost {
+		p.serveBatch(ctx, w, r, group)
+		return
+	}
+
+	group.stats.serverRequests.Add(1)
+
+	var response *pb.Response
+	byteView, err := group.get(ctx, key, r.Header.Get(forwardedHeader) == "")
+	switch {
+	case errors.Is(err, ErrNotFound):
+		response = &pb.Response{NotFound: true}
+	case err != nil:
 		http.Error(w, "Get value failed:"+key, http.StatusNotFound)
 		return
+	default:
+		var accept []string
+		if v := r.Header.Get(AcceptCompressionHeader); v != "" {
+			accept = strings.Split(v, ",")
+		}
+		response = newResponse(byteView, group.codec, accept)
+	}
+
+	body, err := proto.Marshal(response)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// serveBatch answers a batch request whose body is a BatchRequest message for the group.
+func (p *HTTPPool) serveBatch(ctx context.Context, w http.ResponseWriter, r *http.Request, group *Group) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in := &pb.BatchRequest{}
+	if err := proto.Unmarshal(body, in); err != nil {
+		http.Error(w, "decoding request body: "+err.Error(), http.StatusBadRequest)
+		return
 	}
+	in.Group = group.name
 
-	body, err := proto.Marshal(&pb.Response{Value: byteView.ByteSlice()})
+	body, err = proto.Marshal(group.batchResponse(ctx, in))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -115,26 +300,254 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+// requestContext derives the context for serving r, bounded by the deadline sent by the calling peer.
+func requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if v := r.Header.Get(timeoutHeader); v != "" {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return context.WithTimeout(r.Context(), time.Duration(ms)*time.Millisecond)
+		}
+	}
+	return context.WithCancel(r.Context())
+}
+
 // Set sets the list of cache peers in the HTTPPool and initializes the HTTP getters for each peer.
+// Getters of peers that stay in the list are kept.
 func (p *HTTPPool) Set(nodes ...*consistenthash.Node) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.peers = consistenthash.NewMap(defaultReplicas, nil)
-	p.peers.Add(nodes...)
-	p.httpGetters = make(map[string]*httpGetter, len(nodes))
+	members := make(map[string]*consistenthash.Node, len(nodes))
+	getters := make(map[string]*httpGetter, len(nodes))
+	var change MembershipChange
+	for _, node := range nodes {
+		members[node.Name] = node
+		if getter, ok := p.httpGetters[node.Name]; ok {
+			getters[node.Name] = getter
+			continue
+		}
+		getters[node.Name] = p.newGetter(node.Name)
+		change.Added = append(change.Added, node.Name)
+	}
+	for name := range p.nodes {
+		if _, ok := members[name]; !ok {
+			change.Removed = append(change.Removed, name)
+		}
+	}
+	for name := range p.ejected {
+		if _, ok := members[name]; !ok {
+			delete(p.ejected, name)
+		}
+	}
+	p.peers = p.newPlacer()
+	for _, node := range nodes {
+		if !p.ejected[node.Name] {
+			p.peers.Add(node)
+		}
+	}
+	p.nodes = members
+	p.httpGetters = getters
+	p.mu.Unlock()
+
+	p.notify(change)
+}
+
+// AddPeers adds nodes to a running HTTPPool without rebuilding the ring.
+// Nodes that are already members are ignored.
+func (p *HTTPPool) AddPeers(nodes ...*consistenthash.Node) {
+	p.mu.Lock()
+	if p.peers == nil {
+		p.peers = p.newPlacer()
+		p.nodes = make(map[string]*consistenthash.Node)
+		p.httpGetters = make(map[string]*httpGetter)
+	}
+	var change MembershipChange
 	for _, node := range nodes {
-		p.httpGetters[node.Name] = &httpGetter{baseURL: node.Name + p.basePath}
+		if _, ok := p.nodes[node.Name]; ok {
+			continue
+		}
+		p.peers.Add(node)
+		p.nodes[node.Name] = node
+		p.httpGetters[node.Name] = p.newGetter(node.Name)
+		change.Added = append(change.Added, node.Name)
+	}
+	p.mu.Unlock()
+
+	p.notify(change)
+}
+
+// RemovePeers removes the named nodes from a running HTTPPool without rebuilding the ring.
+// Names that are not members are ignored.
+func (p *HTTPPool) RemovePeers(names ...string) {
+	p.mu.Lock()
+	var change MembershipChange
+	for _, name := range names {
+		node, ok := p.nodes[name]
+		if !ok {
+			continue
+		}
+		p.peers.Remove(node)
+		delete(p.nodes, name)
+		delete(p.httpGetters, name)
+		delete(p.ejected, name)
+		delete(p.breakers, name)
+		change.Removed = append(change.Removed, name)
+	}
+	p.mu.Unlock()
+
+	p.notify(change)
+}
+
+// OnMembershipChange registers fn to be called after nodes join or leave the HTTPPool.
+// fn is called without the pool's lock held, so it may call back into the pool.
+func (p *HTTPPool) OnMembershipChange(fn func(MembershipChange)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = fn
+}
+
+// notify reports a non-empty membership change to the registered callback.
+func (p *HTTPPool) notify(change MembershipChange) {
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return
+	}
+	p.mu.Lock()
+	fn := p.onChange
+	p.mu.Unlock()
+	if fn != nil {
+		fn(change)
+	}
+}
+
+// Peers returns the names of the current members, including this node if it is listed.
+func (p *HTTPPool) Peers() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.nodes))
+	for name := range p.nodes {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
-// PickPeer selects a cache peer for a given key using consistent hashing.
+// PickPeer selects a cache peer for a given key using the pool's placement.
 // It returns the selected PeerGetter and a boolean indicating whether a peer was found.
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	if p.peers == nil {
+		return nil, false
+	}
 	if peer, _ := p.peers.SelectNode(key); peer != nil && peer.Name != p.self {
-		p.Log("Pick peer %s", peer)
-		return p.httpGetters[peer.Name], true
+		p.Log("Pick peer %s", peer.Name)
+		return p.peerGetter(peer.Name), true
 	}
 	return nil, false
 }
+
+// Registry returns the registry whose groups the pool serves.
+func (p *HTTPPool) Registry() *Registry {
+	return p.registry
+}
+
+// BasePath returns the path prefix of the peer protocol, under which the pool must be mounted.
+func (p *HTTPPool) BasePath() string {
+	return p.basePath
+}
+
+// Ring returns the members of the pool and their placement.
+func (p *HTTPPool) Ring() Ring {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	nodes := make([]*consistenthash.Node, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		nodes = append(nodes, node)
+	}
+	return newRing(p.self, nodes, p.peers, p.ejected)
+}
+
+// Owner returns the name of the node responsible for key, which may be this node.
+func (p *HTTPPool) Owner(key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers == nil {
+		return "", errors.New("no peers")
+	}
+	node, err := p.peers.SelectNode(key)
+	if err != nil {
+		return "", err
+	}
+	return node.Name, nil
+}
+
+// beginLoad reports a fetch from the named peer to the placement when it tracks loads,
+// and returns the function reporting the end of the fetch.
+func (p *HTTPPool) beginLoad(name string) func() {
+	p.mu.Lock()
+	reporter, ok := p.peers.(LoadReporter)
+	p.mu.Unlock()
+	if !ok {
+		return func() {}
+	}
+	reporter.Inc(name)
+	return func() { reporter.Done(name) }
+}
+
+// beginSelfLoad reports a load served by this node to the placement when it tracks loads,
+// so that this node's own keys move off it when it is overloaded too.
+func (p *HTTPPool) beginSelfLoad() func() {
+	return p.beginLoad(p.self)
+}
+
+// GetAll returns the HTTP getters of every peer except this HTTPPool instance.
+func (p *HTTPPool) GetAll() []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peers := make([]PeerGetter, 0, len(p.httpGetters))
+	for name := range p.httpGetters {
+		if name != p.self {
+			peers = append(peers, p.peerGetter(name))
+		}
+	}
+	return peers
+}
+
+// EnableCircuitBreakers guards every peer with a circuit breaker, so that calls to a failing peer
+// return ErrCircuitOpen at once and the caller falls back to the local getter.
+func (p *HTTPPool) EnableCircuitBreakers(opts BreakerOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	opts = opts.withDefaults()
+	p.breakerOpts = &opts
+	p.breakers = make(map[string]*breakerGetter)
+}
+
+// BreakerStates returns the circuit breaker state of every remote peer, keyed by peer name.
+// It returns nil when circuit breakers are not enabled.
+func (p *HTTPPool) BreakerStates() map[string]BreakerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.breakerOpts == nil {
+		return nil
+	}
+	states := make(map[string]BreakerState, len(p.httpGetters))
+	for name := range p.httpGetters {
+		if name != p.self {
+			states[name] = p.peerGetter(name).(*breakerGetter).breaker.State()
+		}
+	}
+	return states
+}
+
+// peerGetter returns the PeerGetter of the named peer, wrapped by its circuit breaker if enabled.
+// It must be called with p.mu held.
+func (p *HTTPPool) peerGetter(name string) PeerGetter {
+	getter := p.httpGetters[name]
+	if p.breakerOpts == nil {
+		return getter
+	}
+	bg, ok := p.breakers[name]
+	if !ok || bg.peer != PeerGetter(getter) {
+		bg = &breakerGetter{peer: getter, breaker: newCircuitBreaker(*p.breakerOpts)}
+		p.breakers[name] = bg
+	}
+	return bg
+}
@@ -0,0 +1,371 @@
+package tscache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"tscache/compress"
+	"tscache/consistenthash"
+
+	pb "tscache/tscachepb"
+)
+
+// TestHTTPGetter_Deadline tests that the caller's deadline is forwarded to the owner node.
+func TestHTTPGetter_Deadline(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	NewGroupContext("http-deadline", 100, GetterCtxFunc(func(ctx context.Context, key string) ([]byte, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return nil, errors.New("missing deadline")
+		}
+		deadlines <- time.Until(deadline)
+		return []byte(key), nil
+	}))
+
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(pool)
+	defer server.Close()
+
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	out := &pb.Response{}
+	if err := getter.Get(ctx, &pb.Request{Group: "http-deadline", Key: "key1"}, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out.GetValue()) != "key1" {
+		t.Errorf("expected value key1, got %q", out.GetValue())
+	}
+	if remaining := <-deadlines; remaining <= 0 || remaining > 2*time.Second {
+		t.Errorf("expected deadline within 2s, got %v", remaining)
+	}
+}
+
+// TestHTTPGetter_Cancel tests that a cancelled caller does not wait for a slow owner.
+func TestHTTPGetter_Cancel(t *testing.T) {
+	NewGroupContext("http-cancel", 100, GetterCtxFunc(func(ctx context.Context, key string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(pool)
+	defer server.Close()
+
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := getter.Get(ctx, &pb.Request{Group: "http-cancel", Key: "key1"}, &pb.Response{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected cancellation to be prompt, took %v", elapsed)
+	}
+}
+
+// TestHTTPGetter_Remove tests that a DELETE request drops the key from the serving node.
+func TestHTTPGetter_Remove(t *testing.T) {
+	loads := 0
+	NewGroup("http-remove", 100, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}))
+
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(pool)
+	defer server.Close()
+
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+	in := &pb.Request{Group: "http-remove", Key: "key1"}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := getter.Get(ctx, in, &pb.Response{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := getter.Remove(ctx, in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := getter.Get(ctx, in, &pb.Response{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("expected 2 loads around the removal, got %d", loads)
+	}
+}
+
+// TestHTTPPool_Membership tests adding and removing peers on a running pool.
+func TestHTTPPool_Membership(t *testing.T) {
+	pool := NewHTTPPool("http://self")
+	var changes []MembershipChange
+	pool.OnMembershipChange(func(change MembershipChange) {
+		changes = append(changes, change)
+	})
+
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: "http://a"})
+	pool.AddPeers(&consistenthash.Node{Name: "http://b"}, &consistenthash.Node{Name: "http://a"})
+	if got := pool.Peers(); !reflect.DeepEqual(got, []string{"http://a", "http://b", "http://self"}) {
+		t.Fatalf("unexpected peers %v", got)
+	}
+	if len(pool.GetAll()) != 2 {
+		t.Fatalf("expected 2 remote peers, got %d", len(pool.GetAll()))
+	}
+
+	pool.RemovePeers("http://a", "http://unknown")
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: "http://c"})
+
+	want := []MembershipChange{
+		{Added: []string{"http://self", "http://a"}},
+		{Added: []string{"http://b"}},
+		{Removed: []string{"http://a"}},
+		{Added: []string{"http://c"}, Removed: []string{"http://b"}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("expected changes %+v, got %+v", want, changes)
+	}
+
+	// Every key is now owned by self or c.
+	for i := 0; i < 100; i++ {
+		if peer, ok := pool.PickPeer(strconv.Itoa(i)); ok && peer.(*httpGetter).baseURL != "http://c"+defaultBasePath {
+			t.Fatalf("key %d routed to a removed peer %s", i, peer.(*httpGetter).baseURL)
+		}
+	}
+}
+
+// TestHTTPPool_UnexpectedPath tests that paths outside the base path are not found.
+func TestHTTPPool_UnexpectedPath(t *testing.T) {
+	pool := NewHTTPPool("self")
+	rec := httptest.NewRecorder()
+	pool.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/favicon.ico", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a path outside %s, got %d", pool.BasePath(), rec.Code)
+	}
+}
+
+// TestHTTPPool_WithPlacer tests that a pool routes keys with the configured placement.
+func TestHTTPPool_WithPlacer(t *testing.T) {
+	placement := consistenthash.NewRendezvous(nil)
+	pool := NewHTTPPool("http://self", WithPlacer(func() Placer { return placement }))
+	pool.Set(&consistenthash.Node{Name: "http://self"}, &consistenthash.Node{Name: "http://a"})
+
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		owner, _ := placement.SelectNode(key)
+		peer, ok := pool.PickPeer(key)
+		if ok != (owner.Name == "http://a") {
+			t.Fatalf("key %s: placement picked %s but pool returned %v", key, owner.Name, peer)
+		}
+	}
+}
+
+// TestHTTPPool_BoundedLoads tests that fetches and local loads are reported to each node's own
+// load-bounded placement, so that a key whose owner is busy goes to another node, and that the
+// node it goes to loads it itself instead of sending it back to the owner.
+func TestHTTPPool_BoundedLoads(t *testing.T) {
+	entered := make(chan string, 3)
+	release := make(chan struct{})
+	var (
+		names      [3]string
+		pools      [3]*HTTPPool
+		placements [3]*consistenthash.Map
+	)
+	for i := range pools {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pools[i].ServeHTTP(w, r)
+		}))
+		defer server.Close()
+		names[i] = server.URL
+	}
+	for i := range pools {
+		name := names[i]
+		registry := NewRegistry()
+		group := registry.NewGroup("http-bounded", 1<<10, GetterFunc(func(key string) ([]byte, error) {
+			entered <- name
+			<-release
+			return []byte(name), nil
+		}))
+		pools[i] = NewHTTPPool(name, WithRegistry(registry), WithPlacer(func() Placer {
+			placements[i] = consistenthash.NewMap(defaultReplicas, nil)
+			placements[i].SetLoadBound(0.01)
+			return placements[i]
+		}))
+		nodes := make([]*consistenthash.Node, len(names))
+		for j, name := range names {
+			nodes[j] = &consistenthash.Node{Name: name}
+		}
+		pools[i].Set(nodes...)
+		group.RegisterNodes(pools[i])
+	}
+
+	// Find a key owned by another node than the client, and the third node
+	client, owner, other := 0, -1, -1
+	var key string
+	for i := 0; owner < 0; i++ {
+		key = strconv.Itoa(i)
+		name, _ := pools[client].Owner(key)
+		for j := 1; j < len(names); j++ {
+			if names[j] == name {
+				owner, other = j, 3-j
+			}
+		}
+	}
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := pools[client].registry.Get("http-bounded").Get(key)
+		done <- err
+	}()
+	if name := <-entered; name != names[owner] {
+		t.Fatalf("expected the owner to load the key, got %s", name)
+	}
+	if load := placements[client].Load(names[owner]); load != 1 {
+		t.Errorf("expected the client to count one fetch in flight on the owner, got %d", load)
+	}
+	if load := placements[owner].Load(names[owner]); load != 1 {
+		t.Errorf("expected the owner to count its own load, got %d", load)
+	}
+	if peer, ok := pools[client].PickPeer(key); ok && peer.(*httpGetter).baseURL == names[owner]+defaultBasePath {
+		t.Errorf("expected the key to move away from its busy owner")
+	}
+
+	// A request the client forwards to the third node is loaded there, not sent back to the owner
+	go func() {
+		getter := &httpGetter{baseURL: names[other] + defaultBasePath}
+		done <- getter.Get(context.Background(), &pb.Request{Group: "http-bounded", Key: key, Forwarded: true}, &pb.Response{})
+	}()
+	select {
+	case name := <-entered:
+		if name != names[other] {
+			t.Errorf("expected the forwarded request to be loaded by %s, got %s", names[other], name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the forwarded request to be loaded where it was sent")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if load := placements[client].Load(names[owner]) + placements[owner].Load(names[owner]); load != 0 {
+		t.Errorf("expected no load in flight after the loads ended, got %d", load)
+	}
+	if name, _ := pools[client].Owner(key); name != names[owner] {
+		t.Errorf("expected the key to return to its owner")
+	}
+}
+
+// TestHTTPPool_Compression tests that values are compressed on the wire only for callers that accept the codec.
+func TestHTTPPool_Compression(t *testing.T) {
+	value := strings.Repeat(`{"id":1,"name":"compressible"},`, 50)
+	NewGroup("http-compression", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(value), nil
+	}), WithCompression(compress.Gzip))
+
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(pool)
+	defer server.Close()
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+
+	out := &pb.Response{}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "http-compression", Key: "k"}, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.GetCompression() != "" || string(out.GetValue()) != value {
+		t.Errorf("expected an uncompressed value, got compression %q", out.GetCompression())
+	}
+
+	out = &pb.Response{}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "http-compression", Key: "k", AcceptCompression: []string{"lz", "gzip"}}, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.GetCompression() != "gzip" || len(out.GetValue()) >= len(value) {
+		t.Errorf("expected a smaller gzip value, got %d bytes with compression %q", len(out.GetValue()), out.GetCompression())
+	}
+
+	// Peers decode the value transparently
+	view, err := (&Group{name: "http-compression"}).getFromPeer(context.Background(), getter, "k")
+	if err != nil || view.String() != value {
+		t.Errorf("expected the original value, got %d bytes, %v", view.Len(), err)
+	}
+}
+
+// TestHTTPPool_NotFound tests that a missing key is reported as such by the owner and remembered by the caller.
+func TestHTTPPool_NotFound(t *testing.T) {
+	loads := 0
+	NewGroup("http-not-found", 100, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, ErrNotFound
+	}), WithNegativeCache(time.Minute, 100))
+
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(pool)
+	defer server.Close()
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+
+	caller := &Group{name: "http-not-found", negativeTTL: time.Minute}
+	for i := 0; i < 2; i++ {
+		if _, err := caller.getFromPeer(context.Background(), getter, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected the owner to load the missing key once, got %d", loads)
+	}
+	if _, ok := caller.negCache.get("missing"); !ok {
+		t.Error("expected the caller to remember the missing key")
+	}
+}
+
+// TestHTTPPool_WithRegistry tests that a pool serves the groups of its own registry only.
+func TestHTTPPool_WithRegistry(t *testing.T) {
+	newRegistry := func(value string) *Registry {
+		r := NewRegistry()
+		r.NewGroup("http-registry", 100, GetterFunc(func(key string) ([]byte, error) {
+			return []byte(value), nil
+		}))
+		return r
+	}
+	a, b := newRegistry("a"), newRegistry("b")
+	if GetGroup("http-registry") != nil {
+		t.Fatal("group of a registry found in the default registry")
+	}
+
+	for _, tt := range []struct {
+		registry *Registry
+		want     string
+	}{{a, "a"}, {b, "b"}} {
+		pool := NewHTTPPool("owner", WithRegistry(tt.registry))
+		if pool.Registry() != tt.registry {
+			t.Fatal("pool does not return its registry")
+		}
+		server := httptest.NewServer(pool)
+		getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+		out := &pb.Response{}
+		err := getter.Get(context.Background(), &pb.Request{Group: "http-registry", Key: "key"}, out)
+		server.Close()
+		if err != nil || string(out.GetValue()) != tt.want {
+			t.Errorf("Get() = %q, %v, want %q", out.GetValue(), err, tt.want)
+		}
+	}
+
+	pool := NewHTTPPool("owner")
+	server := httptest.NewServer(pool)
+	defer server.Close()
+	getter := &httpGetter{baseURL: server.URL + defaultBasePath}
+	if err := getter.Get(context.Background(), &pb.Request{Group: "http-registry", Key: "key"}, &pb.Response{}); err == nil {
+		t.Error("default pool served a group of another registry")
+	}
+}
@@ -0,0 +1,181 @@
+// Package lfu implements a byte-bounded least-frequently-used cache with O(1) operations.
+package lfu
+
+import (
+	"container/list"
+	"time"
+	"tscache/policy"
+)
+
+// Value is a cached value that reports its size in bytes.
+type Value = policy.Value
+
+// Cache is an LFU cache. Entries are grouped into buckets of equal access frequency kept in
+// ascending order; within a bucket, the least recently used entry is evicted first.
+type Cache struct {
+	maxBytes  int64                         // Maximum cache size in bytes; zero means unbounded
+	nbytes    int64                         // Bytes held by keys and values
+	freqs     *list.List                    // Frequency buckets, lowest frequency at the front
+	cache     map[string]*entry             // Mapping of keys to entries
+	OnEvicted func(key string, value Value) // Called for every entry dropped from the cache
+}
+
+// bucket holds every entry accessed exactly freq times.
+type bucket struct {
+	freq    int        // Access frequency shared by the entries
+	entries *list.List // Entries of the bucket, most recently used at the front
+}
+
+// entry is a cached key/value pair.
+type entry struct {
+	key    string
+	value  Value
+	expire time.Time     // Expiration time; zero means never
+	bucket *list.Element // Element of freqs holding the entry's bucket
+	elem   *list.Element // Element of the bucket's entries holding the entry
+}
+
+// NewCache creates an LFU cache bounded to maxBytes.
+func NewCache(maxBytes int64, onEvicted func(key string, value Value)) *Cache {
+	return &Cache{
+		maxBytes:  maxBytes,
+		freqs:     list.New(),
+		cache:     make(map[string]*entry),
+		OnEvicted: onEvicted,
+	}
+}
+
+// New creates an LFU cache as a policy.Store. It satisfies policy.Policy.
+func New(maxBytes int64, onEvicted func(key string, value Value)) policy.Store {
+	return NewCache(maxBytes, onEvicted)
+}
+
+// Get returns the value for key and increments its frequency.
+func (c *Cache) Get(key string) (Value, bool) {
+	e, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !e.expire.IsZero() && !time.Now().Before(e.expire) {
+		c.removeEntry(e)
+		return nil, false
+	}
+	c.increment(e)
+	return e.value, true
+}
+
+// Peek returns the value for key without incrementing its frequency.
+func (c *Cache) Peek(key string) (Value, time.Time, bool) {
+	e, ok := c.cache[key]
+	if !ok || (!e.expire.IsZero() && !time.Now().Before(e.expire)) {
+		return nil, time.Time{}, false
+	}
+	return e.value, e.expire, true
+}
+
+// Add adds or replaces the value for key; the entry never expires.
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire adds or replaces the value for key. Replacing counts as an access.
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	if e, ok := c.cache[key]; ok {
+		c.nbytes += int64(value.Len()) - int64(e.value.Len())
+		e.value = value
+		e.expire = expire
+		c.increment(e)
+	} else {
+		e := &entry{key: key, value: value, expire: expire}
+		front := c.freqs.Front()
+		if front == nil || front.Value.(*bucket).freq != 1 {
+			front = c.freqs.PushFront(&bucket{freq: 1, entries: list.New()})
+		}
+		e.bucket = front
+		e.elem = front.Value.(*bucket).entries.PushFront(e)
+		c.cache[key] = e
+		c.nbytes += int64(len(key)) + int64(value.Len())
+	}
+	for c.maxBytes != 0 && c.maxBytes < c.nbytes {
+		c.RemoveLeastFrequent()
+	}
+}
+
+// increment moves e to the bucket of the next frequency, creating it if needed.
+func (c *Cache) increment(e *entry) {
+	cur := e.bucket.Value.(*bucket)
+	next := e.bucket.Next()
+	if next == nil || next.Value.(*bucket).freq != cur.freq+1 {
+		next = c.freqs.InsertAfter(&bucket{freq: cur.freq + 1, entries: list.New()}, e.bucket)
+	}
+	cur.entries.Remove(e.elem)
+	if cur.entries.Len() == 0 {
+		c.freqs.Remove(e.bucket)
+	}
+	e.bucket = next
+	e.elem = next.Value.(*bucket).entries.PushFront(e)
+}
+
+// RemoveLeastFrequent evicts the least recently used entry of the lowest frequency.
+func (c *Cache) RemoveLeastFrequent() {
+	front := c.freqs.Front()
+	if front == nil {
+		return
+	}
+	c.removeEntry(front.Value.(*bucket).entries.Back().Value.(*entry))
+}
+
+// Remove drops the entry for key, if present.
+func (c *Cache) Remove(key string) {
+	if e, ok := c.cache[key]; ok {
+		c.removeEntry(e)
+	}
+}
+
+// RemoveExpired drops every entry expired at now and returns how many were dropped.
+func (c *Cache) RemoveExpired(now time.Time) int {
+	removed := 0
+	for _, e := range c.cache {
+		if !e.expire.IsZero() && !now.Before(e.expire) {
+			c.removeEntry(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// removeEntry unlinks e from its bucket and the index and reports the eviction.
+func (c *Cache) removeEntry(e *entry) {
+	b := e.bucket.Value.(*bucket)
+	b.entries.Remove(e.elem)
+	if b.entries.Len() == 0 {
+		c.freqs.Remove(e.bucket)
+	}
+	delete(c.cache, e.key)
+	c.nbytes -= int64(len(e.key)) + int64(e.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Walk calls fn for every entry in eviction order, least frequently used first, until fn returns false.
+func (c *Cache) Walk(fn func(key string, value Value, expire time.Time) bool) {
+	for b := c.freqs.Front(); b != nil; b = b.Next() {
+		for elem := b.Value.(*bucket).entries.Back(); elem != nil; elem = elem.Prev() {
+			e := elem.Value.(*entry)
+			if !fn(e.key, e.value, e.expire) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes returns the number of bytes held by keys and values in the cache.
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
@@ -0,0 +1,79 @@
+package lfu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	lfu := NewCache(int64(0), nil)
+	lfu.Add("key1", String("1234"))
+	if v, ok := lfu.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := lfu.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestEvictLeastFrequent(t *testing.T) {
+	var evicted []string
+	lfu := NewCache(int64(12), func(key string, value Value) {
+		evicted = append(evicted, key)
+	})
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Add("k3", String("v3"))
+	lfu.Get("k1")
+	lfu.Get("k1")
+	lfu.Get("k3")
+
+	// k2 has the lowest frequency.
+	lfu.Add("k4", String("v4"))
+	// k4 and k3 now share frequency 1 and 2; k4 is the only entry seen once.
+	lfu.Add("k5", String("v5"))
+
+	if expect := []string{"k2", "k4"}; !reflect.DeepEqual(expect, evicted) {
+		t.Fatalf("expect evicted keys %v, got %v", expect, evicted)
+	}
+	if lfu.Len() != 3 || lfu.Bytes() != 12 {
+		t.Fatalf("expect 3 entries of 12 bytes, got len=%d bytes=%d", lfu.Len(), lfu.Bytes())
+	}
+}
+
+func TestTieBreaksOnRecency(t *testing.T) {
+	lfu := NewCache(int64(8), nil)
+	lfu.Add("k1", String("v1"))
+	lfu.Add("k2", String("v2"))
+	lfu.Add("k3", String("v3"))
+
+	if _, ok := lfu.Get("k1"); ok {
+		t.Fatalf("least recently used of equal frequency should be evicted")
+	}
+}
+
+func TestExpireAndRemove(t *testing.T) {
+	now := time.Now()
+	lfu := NewCache(int64(0), nil)
+	lfu.AddWithExpire("k1", String("v1"), now.Add(-time.Second))
+	lfu.AddWithExpire("k2", String("v2"), now.Add(time.Hour))
+	lfu.Add("k3", String("v3"))
+
+	if _, ok := lfu.Get("k1"); ok {
+		t.Fatalf("expired k1 should miss")
+	}
+	if n := lfu.RemoveExpired(now.Add(2 * time.Hour)); n != 1 {
+		t.Fatalf("expect 1 expired entry, got %d", n)
+	}
+	lfu.Remove("k3")
+	if lfu.Len() != 0 || lfu.Bytes() != 0 || lfu.freqs.Len() != 0 {
+		t.Fatalf("expect an empty cache, got len=%d bytes=%d buckets=%d", lfu.Len(), lfu.Bytes(), lfu.freqs.Len())
+	}
+}
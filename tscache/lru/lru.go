@@ -1,6 +1,10 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+	"tscache/policy"
+)
 
 type Cache struct {
 	maxBytes  int64
@@ -20,47 +24,104 @@ func NewCache(maxBytes int64, onEvicted func(key string, value Value)) *Cache {
 	}
 }
 
+// New creates an LRU cache as a policy.Store. It satisfies policy.Policy.
+func New(maxBytes int64, onEvicted func(key string, value Value)) policy.Store {
+	return NewCache(maxBytes, onEvicted)
+}
+
 type entry struct {
-	key   string
-	value Value
+	key    string
+	value  Value
+	expire time.Time // zero means the entry never expires
 }
 
-type Value interface {
-	Len() int
+// expired reports whether the entry has passed its expiration time at now.
+func (e *entry) expired(now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
 }
 
+type Value = policy.Value
+
 func (c *Cache) Get(key string) (value Value, ok bool) {
 	if data, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(data)
 		kv := data.Value.(*entry)
+		if kv.expired(time.Now()) {
+			c.removeElement(data)
+			return nil, false
+		}
+		c.ll.MoveToFront(data)
 		return kv.value, true
 	}
 	return nil, false
 }
 
+// Peek returns the value for key without moving it to the front.
+func (c *Cache) Peek(key string) (value Value, expire time.Time, ok bool) {
+	if data, ok := c.cache[key]; ok {
+		kv := data.Value.(*entry)
+		if !kv.expired(time.Now()) {
+			return kv.value, kv.expire, true
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+// Remove removes the entry for key, if present.
+func (c *Cache) Remove(key string) {
+	if data, ok := c.cache[key]; ok {
+		c.removeElement(data)
+	}
+}
+
 func (c *Cache) RemoveOldst() {
 	data := c.ll.Back()
-	userData := data.Value.(*entry)
-	if userData != nil {
-		delete(c.cache, userData.key)
-		c.ll.Remove(data)
-		c.nbytes = c.nbytes - int64(len(userData.key)) - int64(userData.value.Len())
-		if c.OnEvicted != nil {
-			c.OnEvicted(userData.key, userData.value)
+	if data != nil {
+		c.removeElement(data)
+	}
+}
+
+// RemoveExpired removes every entry that has expired at now and returns how many were removed.
+func (c *Cache) RemoveExpired(now time.Time) int {
+	removed := 0
+	for data := c.ll.Back(); data != nil; {
+		prev := data.Prev()
+		if data.Value.(*entry).expired(now) {
+			c.removeElement(data)
+			removed++
 		}
+		data = prev
+	}
+	return removed
+}
+
+func (c *Cache) removeElement(data *list.Element) {
+	userData := data.Value.(*entry)
+	delete(c.cache, userData.key)
+	c.ll.Remove(data)
+	c.nbytes = c.nbytes - int64(len(userData.key)) - int64(userData.value.Len())
+	if c.OnEvicted != nil {
+		c.OnEvicted(userData.key, userData.value)
 	}
 }
 
 func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire adds a value that is dropped once expire has passed. A zero expire never expires.
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
 	if data, ok := c.cache[key]; ok {
 		userData := data.Value.(*entry)
 		oldSize := userData.value.Len()
 		c.ll.MoveToFront(data)
 		c.nbytes = c.nbytes - int64(oldSize) + int64(value.Len())
+		userData.value = value
+		userData.expire = expire
 	} else {
 		newData := c.ll.PushFront(&entry{
-			key:   key,
-			value: value,
+			key:    key,
+			value:  value,
+			expire: expire,
 		})
 		c.cache[key] = newData
 		c.nbytes += int64(len(key)) + int64(value.Len())
@@ -70,6 +131,21 @@ func (c *Cache) Add(key string, value Value) {
 	}
 }
 
+// Walk calls fn for every entry from the least to the most recently used, until fn returns false.
+func (c *Cache) Walk(fn func(key string, value Value, expire time.Time) bool) {
+	for data := c.ll.Back(); data != nil; data = data.Prev() {
+		kv := data.Value.(*entry)
+		if !fn(kv.key, kv.value, kv.expire) {
+			return
+		}
+	}
+}
+
 func (c *Cache) Len() int {
 	return len(c.cache)
 }
+
+// Bytes returns the number of bytes held by keys and values in the cache.
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
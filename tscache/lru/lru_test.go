@@ -3,6 +3,7 @@ package lru
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -53,3 +54,106 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestAddUpdatesValue(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	lru.Add("key1", String("56"))
+	if v, ok := lru.Get("key1"); !ok || string(v.(String)) != "56" {
+		t.Fatalf("update key1=56 failed")
+	}
+	if lru.Bytes() != int64(len("key1")+len("56")) {
+		t.Fatalf("expect %d bytes, got %d", len("key1")+len("56"), lru.Bytes())
+	}
+}
+
+func TestExpire(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.AddWithExpire("key1", String("1234"), time.Now().Add(-time.Second))
+	lru.AddWithExpire("key2", String("5678"), time.Now().Add(time.Hour))
+	if _, ok := lru.Get("key1"); ok {
+		t.Fatalf("expired key1 should miss")
+	}
+	if _, ok := lru.Get("key2"); !ok {
+		t.Fatalf("cache hit key2 failed")
+	}
+	if lru.Len() != 1 || lru.Bytes() != int64(len("key2")+len("5678")) {
+		t.Fatalf("expired key1 should be reclaimed, len=%d bytes=%d", lru.Len(), lru.Bytes())
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	now := time.Now()
+	lru := NewCache(int64(0), nil)
+	lru.AddWithExpire("k1", String("v1"), now.Add(-time.Second))
+	lru.Add("k2", String("v2"))
+	lru.AddWithExpire("k3", String("v3"), now)
+	lru.AddWithExpire("k4", String("v4"), now.Add(time.Hour))
+
+	if n := lru.RemoveExpired(now); n != 2 {
+		t.Fatalf("expect 2 expired entries, got %d", n)
+	}
+	if lru.Len() != 2 || lru.Bytes() != 8 {
+		t.Fatalf("expect 2 entries of 8 bytes, got len=%d bytes=%d", lru.Len(), lru.Bytes())
+	}
+}
+
+func TestRemove(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	lru.Remove("key1")
+	lru.Remove("key2")
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 0 || lru.Bytes() != 0 {
+		t.Fatalf("Remove key1 failed")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("key1", String("1"))
+	lru.Add("key2", String("2"))
+	lru.Add("key3", String("3"))
+	lru.Get("key1")
+
+	var keys []string
+	lru.Walk(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if expect := []string{"key2", "key3", "key1"}; !reflect.DeepEqual(keys, expect) {
+		t.Fatalf("expect walk order %v, got %v", expect, keys)
+	}
+
+	keys = nil
+	lru.Walk(func(key string, value Value, expire time.Time) bool {
+		keys = append(keys, key)
+		return false
+	})
+	if len(keys) != 1 {
+		t.Fatalf("expect walk to stop after 1 entry, got %v", keys)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	lru := NewCache(int64(0), nil)
+	lru.Add("key1", String("1"))
+	lru.AddWithExpire("key2", String("2"), time.Now().Add(-time.Second))
+	lru.Add("key3", String("3"))
+
+	if v, expire, ok := lru.Peek("key1"); !ok || string(v.(String)) != "1" || !expire.IsZero() {
+		t.Fatalf("unexpected peek result %v, %v, %v", v, expire, ok)
+	}
+	if _, _, ok := lru.Peek("key2"); ok {
+		t.Fatal("expect expired entry to be missing")
+	}
+	if lru.Len() != 3 {
+		t.Fatalf("expect peek to leave expired entries, got %d entries", lru.Len())
+	}
+
+	// key1 stays the least recently used entry
+	lru.RemoveOldst()
+	lru.RemoveOldst()
+	if _, _, ok := lru.Peek("key1"); ok {
+		t.Fatal("expect peek not to refresh recency")
+	}
+}
@@ -0,0 +1,92 @@
+package tscache
+
+import (
+	"time"
+	"tscache/compress"
+	"tscache/policy"
+)
+
+// GroupOption configures optional behaviour of a Group created by NewGroup or NewGroupContext.
+type GroupOption func(g *Group)
+
+// WithTTL sets the default lifetime of values loaded by the group's getter.
+// Values returned by an ExpiringGetter with a positive TTL use their own lifetime instead.
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithSweepInterval sets how often expired entries are reclaimed from the group's cache in the background.
+func WithSweepInterval(interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.sweepInterval = interval
+	}
+}
+
+// WithHotCacheBytes sets the part of the group's byte budget reserved for values fetched from peers.
+// The main cache gets the remainder, so n must be less than the group's budget. Zero or a negative
+// value disables the hot cache, as does the default share of a budget too small to split.
+func WithHotCacheBytes(n int64) GroupOption {
+	return func(g *Group) {
+		g.hotCacheBytes = n
+	}
+}
+
+// WithHotCacheSampling stores one in every n values fetched from peers in the hot cache.
+// A value of 1 stores every peer-fetched value; zero or less disables the hot cache.
+func WithHotCacheSampling(n int) GroupOption {
+	return func(g *Group) {
+		g.hotCacheSampling = n
+	}
+}
+
+// WithPolicy sets the eviction policy of the group's caches, such as lru.New, lfu.New, arc.New or tinylfu.New.
+// The default policy is LRU.
+func WithPolicy(p policy.Policy) GroupOption {
+	return func(g *Group) {
+		g.policy = p
+	}
+}
+
+// WithShards splits the group's main cache into n independently locked shards that share its byte budget.
+// Sharding reduces lock contention under concurrent load; values of one or less keep a single shard.
+// A small budget gets fewer shards, so that each shard can still hold entries.
+func WithShards(n int) GroupOption {
+	return func(g *Group) {
+		g.shards = n
+	}
+}
+
+// WithCompression stores the group's values compressed with c, such as compress.LZ or compress.Gzip,
+// so that the main cache's byte budget counts compressed sizes. Peers that accept the codec also
+// receive the group's values compressed. Values that do not shrink are kept as is.
+func WithCompression(c compress.Codec) GroupOption {
+	return func(g *Group) {
+		g.codec = c
+	}
+}
+
+// WithNegativeCache remembers keys whose getter returned ErrNotFound for ttl, in a cache of its own
+// bounded to cacheBytes, so that repeated lookups of missing keys do not reach the origin.
+// Peers that do not own a key remember it as missing too when the owner reports it so.
+func WithNegativeCache(ttl time.Duration, cacheBytes int64) GroupOption {
+	return func(g *Group) {
+		g.negativeTTL = ttl
+		g.negCache.cacheBytes = cacheBytes
+	}
+}
+
+// WithStaleWhileRevalidate serves values older than softTTL at once while a background refresh
+// reloads them, so that callers do not wait for the origin. Values past their hard expiration,
+// set by WithTTL or an ExpiringGetter, are not served stale: callers wait for a reload.
+// At most maxRefreshes refreshes run concurrently; a stale hit beyond the limit is served
+// without a refresh, and a later hit tries again. A maxRefreshes of zero or less uses a default.
+func WithStaleWhileRevalidate(softTTL time.Duration, maxRefreshes int) GroupOption {
+	return func(g *Group) {
+		g.softTTL = softTTL
+		if maxRefreshes > 0 {
+			g.refreshes = make(chan struct{}, maxRefreshes)
+		}
+	}
+}
@@ -1,17 +1,123 @@
 package tscache
 
-import pb "tscache/tscachepb"
+import (
+	"context"
+	"slices"
+	"tscache/compress"
+	"tscache/consistenthash"
+	pb "tscache/tscachepb"
+
+	"google.golang.org/grpc"
+)
 
 // PeerPicker is an interface for picking a peer based on a given key.
 type PeerPicker interface {
 	// PickPeer selects a peer based on the provided key.
 	// It returns the selected PeerGetter and a boolean indicating whether a peer was found.
 	PickPeer(key string) (peer PeerGetter, ok bool)
+
+	// GetAll returns every remote peer, excluding the current node.
+	// It is used to broadcast invalidations to the whole cluster.
+	GetAll() []PeerGetter
 }
 
 // PeerGetter is an interface for getting data from a peer.
 type PeerGetter interface {
 	// Get fetches the value associated with the provided key from a peer.
 	// It takes a Request message as input and populates the Response message with the fetched data.
-	Get(in *pb.Request, out *pb.Response) error
+	// The context's deadline is forwarded to the peer so that it can stop work the caller no longer waits for.
+	Get(ctx context.Context, in *pb.Request, out *pb.Response) error
+
+	// Remove drops the value associated with the provided key from the peer's caches.
+	Remove(ctx context.Context, in *pb.Request) error
+}
+
+// Placer maps keys to the nodes of a cluster.
+// consistenthash provides a hash ring (Map), rendezvous hashing, Maglev and jump consistent hashing.
+type Placer interface {
+	// Add adds nodes to the placement.
+	Add(nodes ...*consistenthash.Node)
+	// Remove removes nodes from the placement.
+	Remove(nodes ...*consistenthash.Node)
+	// SelectNode selects the node responsible for a given key.
+	SelectNode(key string) (*consistenthash.Node, error)
+}
+
+var (
+	_ Placer = (*consistenthash.Map)(nil)
+	_ Placer = (*consistenthash.Rendezvous)(nil)
+	_ Placer = (*consistenthash.Maglev)(nil)
+	_ Placer = (*consistenthash.Jump)(nil)
+)
+
+// LoadReporter is implemented by placements that bound the in-flight load of each node,
+// such as a consistenthash.Map with a load bound. HTTPPool reports every peer fetch to it.
+type LoadReporter interface {
+	// Inc records the start of a request served by the named node.
+	Inc(name string)
+	// Done records the end of a request started with Inc.
+	Done(name string)
+}
+
+var _ LoadReporter = (*consistenthash.Map)(nil)
+
+// poolOptions holds the configuration shared by HTTPPool and GRPCPool.
+type poolOptions struct {
+	registry  *Registry         // registry holds the groups served to peers.
+	newPlacer func() Placer     // newPlacer creates the placement of cache peers.
+	dialOpts  []grpc.DialOption // dialOpts are used by a GRPCPool when connecting to peers.
+}
+
+// PoolOption configures optional behaviour of a pool created by NewHTTPPool or NewGRPCPool.
+type PoolOption func(o *poolOptions)
+
+// WithPlacer sets how keys are mapped to peers. newPlacer is called whenever the pool
+// rebuilds its placement. The default is a consistent hash ring.
+func WithPlacer(newPlacer func() Placer) PoolOption {
+	return func(o *poolOptions) {
+		o.newPlacer = newPlacer
+	}
+}
+
+// WithRegistry sets the registry whose groups the pool serves. The default is DefaultRegistry.
+func WithRegistry(r *Registry) PoolOption {
+	return func(o *poolOptions) {
+		o.registry = r
+	}
+}
+
+// newPoolOptions applies opts over the defaults.
+func newPoolOptions(opts []PoolOption) poolOptions {
+	o := poolOptions{
+		registry: DefaultRegistry,
+		newPlacer: func() Placer {
+			return consistenthash.NewMap(defaultReplicas, nil)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// MembershipChange describes the nodes that joined or left a peer pool.
+type MembershipChange struct {
+	Added   []string // Added lists the names of the nodes that joined.
+	Removed []string // Removed lists the names of the nodes that left.
+}
+
+// newResponse builds the Response message sent to a peer for a value.
+// The value is compressed with codec when the peer accepts it and compression makes it smaller.
+func newResponse(view ByteView, codec compress.Codec, accept []string) *pb.Response {
+	response := &pb.Response{Value: view.ByteSlice()}
+	if expire := view.Expire(); !expire.IsZero() {
+		response.Expire = expire.UnixNano()
+	}
+	if codec != nil && slices.Contains(accept, codec.Name()) {
+		if b, err := codec.Encode(view.B); err == nil && len(b) < view.Len() {
+			response.Value = b
+			response.Compression = codec.Name()
+		}
+	}
+	return response
 }
@@ -0,0 +1,40 @@
+// Package policy defines the interface shared by the eviction policies that back a tscache cache.
+package policy
+
+import "time"
+
+// Value is a cached value that reports its size in bytes.
+type Value interface {
+	Len() int
+}
+
+// Store is a byte-bounded, unsynchronized key/value store with its own eviction policy.
+// Implementations account len(key)+value.Len() bytes per entry and call the eviction
+// callback given to their constructor for every entry they drop.
+type Store interface {
+	// Get returns the live value for key. Expired entries are dropped and reported as missing.
+	Get(key string) (value Value, ok bool)
+	// Peek returns the live value for key and its expiration time without recording the access.
+	// Expired entries are reported as missing but left in place.
+	Peek(key string) (value Value, expire time.Time, ok bool)
+	// Add adds or replaces the value for key; the entry never expires.
+	Add(key string, value Value)
+	// AddWithExpire adds or replaces the value for key; the entry expires at expire unless it is zero.
+	AddWithExpire(key string, value Value, expire time.Time)
+	// Remove drops the entry for key, if present.
+	Remove(key string)
+	// RemoveExpired drops every entry expired at now and returns how many were dropped.
+	RemoveExpired(now time.Time) int
+	// Walk calls fn for every entry, including expired entries not reclaimed yet, until fn returns false.
+	// Entries are visited roughly in eviction order, so that adding them back in the same order to
+	// an empty store reproduces their recency. fn must not modify the store.
+	Walk(fn func(key string, value Value, expire time.Time) bool)
+	// Len returns the number of entries held.
+	Len() int
+	// Bytes returns the number of bytes held by keys and values.
+	Bytes() int64
+}
+
+// Policy creates a Store bounded to maxBytes (zero means unbounded) that calls onEvicted
+// for every entry it drops.
+type Policy func(maxBytes int64, onEvicted func(key string, value Value)) Store
@@ -0,0 +1,106 @@
+package tscache
+
+import (
+	"hash/maphash"
+	"time"
+	"tscache/compress"
+	"tscache/policy"
+)
+
+// minShardBytes is the smallest budget of a shard. A tiny shard evicts its entries on almost
+// every add, so a small budget is split into fewer shards rather than left to hold nothing.
+const minShardBytes = 4 << 10
+
+// shardedCache splits a cache into independently locked shards so that concurrent
+// lookups of different keys do not serialize on a single mutex.
+type shardedCache struct {
+	seed   maphash.Seed // seed of the hash selecting a key's shard
+	shards []*cache     // shards share the byte budget evenly
+}
+
+// newShardedCache creates a cache of up to n shards that together hold at most cacheBytes.
+// A bounded budget gets no more shards than give each one minShardBytes, and at least one.
+func newShardedCache(n int, cacheBytes int64, p policy.Policy, sweepInterval time.Duration, codec compress.Codec) *shardedCache {
+	if cacheBytes > 0 && cacheBytes/int64(n) < minShardBytes {
+		n = int(max(cacheBytes/minShardBytes, 1))
+	}
+	c := &shardedCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*cache, n),
+	}
+	for i := range c.shards {
+		shardBytes := cacheBytes / int64(n)
+		if int64(i) < cacheBytes%int64(n) {
+			shardBytes++
+		}
+		c.shards[i] = &cache{cacheBytes: shardBytes, policy: p, sweepInterval: sweepInterval, codec: codec}
+	}
+	return c
+}
+
+// shard returns the shard responsible for key.
+func (c *shardedCache) shard(key string) *cache {
+	return c.shards[maphash.String(c.seed, key)%uint64(len(c.shards))]
+}
+
+// add adds a key-value pair to the key's shard.
+func (c *shardedCache) add(key string, value ByteView) {
+	c.shard(key).add(key, value)
+}
+
+// get retrieves the value associated with the given key from its shard.
+func (c *shardedCache) get(key string) (ByteView, bool) {
+	return c.shard(key).get(key)
+}
+
+// peek retrieves the value for key from its shard without recording the access.
+func (c *shardedCache) peek(key string) (ByteView, bool) {
+	return c.shard(key).peek(key)
+}
+
+// purge drops every entry of every shard.
+func (c *shardedCache) purge() {
+	for _, shard := range c.shards {
+		shard.purge()
+	}
+}
+
+// close drops every entry of every shard and stops their sweepers.
+func (c *shardedCache) close() {
+	for _, shard := range c.shards {
+		shard.close()
+	}
+}
+
+// remove deletes the entry for key from its shard, if present.
+func (c *shardedCache) remove(key string) {
+	c.shard(key).remove(key)
+}
+
+// stats returns the statistics summed over all shards.
+func (c *shardedCache) stats() CacheStats {
+	var s CacheStats
+	for _, shard := range c.shards {
+		ss := shard.stats()
+		s.Bytes += ss.Bytes
+		s.Items += ss.Items
+		s.Gets += ss.Gets
+		s.Hits += ss.Hits
+		s.DecodeErrors += ss.DecodeErrors
+		s.Evictions += ss.Evictions
+		s.Removals += ss.Removals
+		s.Expirations += ss.Expirations
+	}
+	return s
+}
+
+// walk calls fn for every entry, one shard after the other, until fn returns false.
+// It reports whether every entry was walked. The recency order is kept within each shard only.
+func (c *shardedCache) walk(fn func(key string, value ByteView) bool) bool {
+	for _, shard := range c.shards {
+		if !shard.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
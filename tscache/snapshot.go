@@ -0,0 +1,252 @@
+package tscache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A snapshot holds the live entries of a group's main cache, least recently used first:
+//
+//	magic "TSCS" | version (1 byte) | uvarint len(group) | group | uvarint count |
+//	count × (uvarint len(key) | key | uvarint len(value) | value | varint expiry in Unix nanoseconds, 0 for never) |
+//	CRC-32C of all the preceding bytes (4 bytes, big endian)
+const (
+	snapshotMagic   = "TSCS"
+	snapshotVersion = 1
+)
+
+// ErrInvalidSnapshot is returned when restoring data that is not a valid snapshot.
+var ErrInvalidSnapshot = errors.New("tscache: invalid snapshot")
+
+var snapshotTable = crc32.MakeTable(crc32.Castagnoli)
+
+// snapshotEntry is an entry of a snapshot.
+type snapshotEntry struct {
+	key   string
+	value ByteView
+}
+
+// Snapshot writes the live entries of the group's main cache to w, in an order that Restore
+// uses to rebuild their recency. Values fetched from peers, held in the hot cache, are left out.
+func (g *Group) Snapshot(w io.Writer) error {
+	var entries []snapshotEntry
+	now := time.Now()
+	g.mainCache.walk(func(key string, value ByteView) bool {
+		if expire := value.Expire(); expire.IsZero() || now.Before(expire) {
+			entries = append(entries, snapshotEntry{key: key, value: value})
+		}
+		return true
+	})
+
+	sum := crc32.New(snapshotTable)
+	bw := bufio.NewWriter(io.MultiWriter(w, sum))
+	var buf []byte
+	buf = append(buf, snapshotMagic...)
+	buf = append(buf, snapshotVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(g.name)))
+	buf = append(buf, g.name...)
+	buf = binary.AppendUvarint(buf, uint64(len(entries)))
+	bw.Write(buf)
+	for _, entry := range entries {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(entry.key)))
+		bw.Write(buf)
+		bw.WriteString(entry.key)
+		buf = binary.AppendUvarint(buf[:0], uint64(entry.value.Len()))
+		bw.Write(buf)
+		bw.Write(entry.value.B)
+		var expire int64
+		if e := entry.value.Expire(); !e.IsZero() {
+			expire = e.UnixNano()
+		}
+		buf = binary.AppendVarint(buf[:0], expire)
+		bw.Write(buf)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	_, err := w.Write(binary.BigEndian.AppendUint32(nil, sum.Sum32()))
+	return err
+}
+
+// Restore adds the entries of a snapshot written by Snapshot to the group's main cache, replacing
+// the values cached for the same keys. Entries that expired since the snapshot are skipped.
+// With stale-while-revalidate, restored entries are stale: their age is unknown, so they are
+// served and refreshed in the background. Nothing is added unless the whole snapshot is valid.
+func (g *Group) Restore(r io.Reader) error {
+	br := bufio.NewReader(r)
+	sr := &snapshotReader{r: br, sum: crc32.New(snapshotTable)}
+
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(sr, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if string(header[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: bad magic", ErrInvalidSnapshot)
+	}
+	if header[len(snapshotMagic)] != snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, header[len(snapshotMagic)])
+	}
+	name, err := sr.readBytes()
+	if err != nil {
+		return err
+	}
+	if string(name) != g.name {
+		return fmt.Errorf("tscache: snapshot of group %q cannot be restored into group %q", name, g.name)
+	}
+	count, err := sr.readUvarint()
+	if err != nil {
+		return err
+	}
+
+	var entries []snapshotEntry
+	for i := uint64(0); i < count; i++ {
+		key, err := sr.readBytes()
+		if err != nil {
+			return err
+		}
+		value, err := sr.readBytes()
+		if err != nil {
+			return err
+		}
+		expire, err := binary.ReadVarint(sr)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		entry := snapshotEntry{key: string(key), value: ByteView{B: value}}
+		if expire != 0 {
+			entry.value.e = time.Unix(0, expire)
+		}
+		entries = append(entries, entry)
+	}
+
+	want := sr.sum.Sum32()
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(br, got); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if binary.BigEndian.Uint32(got) != want {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if expire := entry.value.Expire(); expire.IsZero() || now.Before(expire) {
+			if g.softTTL > 0 {
+				entry.value.s = now
+			}
+			g.mainCache.add(entry.key, entry.value)
+		}
+	}
+	return nil
+}
+
+// snapshotReader reads a snapshot while computing its checksum.
+type snapshotReader struct {
+	r   *bufio.Reader
+	sum hash.Hash32
+}
+
+// Read reads into p and adds the bytes read to the checksum.
+func (s *snapshotReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.sum.Write(p[:n])
+	return n, err
+}
+
+// ReadByte reads a byte and adds it to the checksum.
+func (s *snapshotReader) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.sum.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readUvarint reads an unsigned varint.
+func (s *snapshotReader) readUvarint() (uint64, error) {
+	n, err := binary.ReadUvarint(s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	return n, nil
+}
+
+// readBytes reads a length-prefixed byte string. The buffer grows with the data actually read,
+// so that a corrupt length cannot trigger a huge allocation.
+func (s *snapshotReader) readBytes() ([]byte, error) {
+	n, err := s.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, s, int64(n)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SnapshotFile writes a snapshot of the group to path. The snapshot is written to a temporary
+// file renamed over path, so that path always holds a complete snapshot.
+func (g *Group) SnapshotFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	err = g.Snapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// RestoreFile restores a snapshot written by SnapshotFile. A missing file is not an error,
+// so that the first start of a node begins with an empty cache.
+func (g *Group) RestoreFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.Restore(f)
+}
+
+// StartSnapshots writes a snapshot of the group to path every interval until ctx is done.
+func (g *Group) StartSnapshots(ctx context.Context, path string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := g.SnapshotFile(path); err != nil {
+					log.Printf("[TSCache] Failed to snapshot group %s: %v", g.name, err)
+				}
+			}
+		}
+	}()
+}
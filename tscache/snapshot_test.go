@@ -0,0 +1,134 @@
+package tscache
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// keysOf returns the keys of the group's main cache in walk order.
+func keysOf(g *Group) []string {
+	var keys []string
+	g.mainCache.walk(func(key string, value ByteView) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// TestGroup_SnapshotRestore tests that a restored group holds the same entries in the same recency order.
+func TestGroup_SnapshotRestore(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte("v-" + key), nil })
+	g := NewGroup("snapshot-restore", 1<<20, getter)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		g.Get(key)
+	}
+	g.Get("b")
+	g.mainCache.add("ttl", ByteView{B: []byte("soon"), e: time.Now().Add(time.Hour)})
+
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	restored := NewGroup("snapshot-restore", 1<<20, getter)
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := keysOf(restored), []string{"a", "c", "d", "b", "ttl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected keys %v, got %v", want, got)
+	}
+	view, ok := restored.mainCache.get("ttl")
+	if !ok || view.String() != "soon" || view.Expire().IsZero() {
+		t.Errorf("expected the expiring entry to keep its expiry, got %v %v", view, view.Expire())
+	}
+	if view, _ := restored.mainCache.get("b"); view.String() != "v-b" {
+		t.Errorf("expected v-b, got %q", view)
+	}
+}
+
+// TestGroup_RestoreSkipsExpired tests that entries expired since the snapshot are not restored.
+func TestGroup_RestoreSkipsExpired(t *testing.T) {
+	g := NewGroup("snapshot-expired", 1<<20, GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil }))
+	g.mainCache.add("short", ByteView{B: []byte("x"), e: time.Now().Add(30 * time.Millisecond)})
+	g.mainCache.add("long", ByteView{B: []byte("y")})
+
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(40 * time.Millisecond)
+
+	restored := NewGroup("snapshot-expired", 1<<20, GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil }))
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := keysOf(restored); !reflect.DeepEqual(got, []string{"long"}) {
+		t.Errorf("expected only the long-lived entry, got %v", got)
+	}
+}
+
+// TestGroup_RestoreInvalid tests that corrupt, truncated or foreign snapshots are rejected as a whole.
+func TestGroup_RestoreInvalid(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil })
+	g := NewGroup("snapshot-invalid", 1<<20, getter)
+	g.Get("key1")
+	g.Get("key2")
+	var buf bytes.Buffer
+	if err := g.Snapshot(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+
+	corrupt := bytes.Clone(data)
+	corrupt[len(corrupt)-6] ^= 0xff
+	for name, input := range map[string][]byte{
+		"corrupt":   corrupt,
+		"truncated": data[:len(data)-2],
+		"empty":     nil,
+		"magic":     []byte("NOPE"),
+	} {
+		restored := NewGroup("snapshot-invalid", 1<<20, getter)
+		if err := restored.Restore(bytes.NewReader(input)); !errors.Is(err, ErrInvalidSnapshot) {
+			t.Errorf("%s: expected ErrInvalidSnapshot, got %v", name, err)
+		}
+		if keys := keysOf(restored); len(keys) != 0 {
+			t.Errorf("%s: expected nothing restored, got %v", name, keys)
+		}
+	}
+
+	other := NewGroup("snapshot-other", 1<<20, getter)
+	if err := other.Restore(bytes.NewReader(data)); err == nil {
+		t.Error("expected an error restoring another group's snapshot")
+	}
+}
+
+// TestGroup_SnapshotFile tests writing and reading snapshot files.
+func TestGroup_SnapshotFile(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil })
+	g := NewGroup("snapshot-file", 1<<20, getter)
+	path := filepath.Join(t.TempDir(), "snapshot")
+
+	// A missing snapshot is a cold start
+	if err := g.RestoreFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	g.Get("key1")
+	if err := g.SnapshotFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := NewGroup("snapshot-file", 1<<20, getter)
+	if err := restored.RestoreFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := keysOf(restored); !reflect.DeepEqual(got, []string{"key1"}) {
+		t.Errorf("expected key1 restored, got %v", got)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Errorf("expected no temporary files left, got %v", matches)
+	}
+}
@@ -0,0 +1,128 @@
+package tscache
+
+import "sync/atomic"
+
+// Stats are per-group statistics.
+type Stats struct {
+	Gets           int64 // Gets counts every Get request, including those from peers.
+	CacheHits      int64 // CacheHits counts Gets served by the main or hot cache.
+	NegativeHits   int64 // NegativeHits counts Gets answered with ErrNotFound by the negative cache.
+	StaleHits      int64 // StaleHits counts main cache hits past their soft TTL, served while refreshing.
+	Refreshes      int64 // Refreshes counts background refreshes of stale values.
+	RefreshErrs    int64 // RefreshErrs counts background refreshes that failed.
+	PeerLoads      int64 // PeerLoads counts values fetched successfully from a peer.
+	PeerErrors     int64 // PeerErrors counts failed fetches from a peer.
+	PeerRejects    int64 // PeerRejects counts fetches skipped because the peer's circuit breaker was open.
+	Loads          int64 // Loads counts Gets that missed both caches.
+	LoadsDeduped   int64 // LoadsDeduped counts loads left after singleflight deduplication.
+	LocalLoads     int64 // LocalLoads counts values loaded successfully by the getter.
+	LocalLoadErrs  int64 // LocalLoadErrs counts failed loads by the getter.
+	ServerRequests int64 // ServerRequests counts Gets that came over the network from peers.
+
+	OpenBreakers     int64 // OpenBreakers is the number of peers whose circuit breaker is open.
+	HalfOpenBreakers int64 // HalfOpenBreakers is the number of peers whose circuit breaker is half-open.
+}
+
+// groupStats holds the live counters behind Stats.
+type groupStats struct {
+	gets           atomic.Int64
+	cacheHits      atomic.Int64
+	negativeHits   atomic.Int64
+	staleHits      atomic.Int64
+	refreshes      atomic.Int64
+	refreshErrs    atomic.Int64
+	peerLoads      atomic.Int64
+	peerErrors     atomic.Int64
+	peerRejects    atomic.Int64
+	loads          atomic.Int64
+	loadsDeduped   atomic.Int64
+	localLoads     atomic.Int64
+	localLoadErrs  atomic.Int64
+	serverRequests atomic.Int64
+}
+
+// snapshot returns a copy of the counters.
+func (s *groupStats) snapshot() Stats {
+	return Stats{
+		Gets:           s.gets.Load(),
+		CacheHits:      s.cacheHits.Load(),
+		NegativeHits:   s.negativeHits.Load(),
+		StaleHits:      s.staleHits.Load(),
+		Refreshes:      s.refreshes.Load(),
+		RefreshErrs:    s.refreshErrs.Load(),
+		PeerLoads:      s.peerLoads.Load(),
+		PeerErrors:     s.peerErrors.Load(),
+		PeerRejects:    s.peerRejects.Load(),
+		Loads:          s.loads.Load(),
+		LoadsDeduped:   s.loadsDeduped.Load(),
+		LocalLoads:     s.localLoads.Load(),
+		LocalLoadErrs:  s.localLoadErrs.Load(),
+		ServerRequests: s.serverRequests.Load(),
+	}
+}
+
+// CacheType selects one of a Group's caches.
+type CacheType int
+
+const (
+	// MainCache is the cache for keys this node owns.
+	MainCache CacheType = iota + 1
+	// HotCache is the cache for a sample of values fetched from peers.
+	HotCache
+	// NegativeCache is the cache of keys missing at the origin.
+	NegativeCache
+)
+
+// String returns the name of the cache.
+func (t CacheType) String() string {
+	switch t {
+	case MainCache:
+		return "main"
+	case HotCache:
+		return "hot"
+	case NegativeCache:
+		return "negative"
+	default:
+		return "unknown"
+	}
+}
+
+// CacheStats are statistics of one of a Group's caches.
+type CacheStats struct {
+	Bytes        int64 // Bytes is the size of all keys and values held.
+	Items        int64 // Items is the number of entries held.
+	Gets         int64 // Gets counts lookups.
+	Hits         int64 // Hits counts lookups that found a live entry.
+	DecodeErrors int64 // DecodeErrors counts lookups that found an entry failing to decompress, served as misses.
+	Evictions    int64 // Evictions counts entries dropped to make room for others.
+	Removals     int64 // Removals counts entries dropped by Remove.
+	Expirations  int64 // Expirations counts entries dropped because they expired.
+}
+
+// Stats returns a snapshot of the group's statistics, with the current breaker states of its peers.
+func (g *Group) Stats() Stats {
+	s := g.stats.snapshot()
+	for _, state := range g.BreakerStates() {
+		switch state {
+		case BreakerOpen:
+			s.OpenBreakers++
+		case BreakerHalfOpen:
+			s.HalfOpenBreakers++
+		}
+	}
+	return s
+}
+
+// CacheStats returns statistics of the selected cache.
+func (g *Group) CacheStats(which CacheType) CacheStats {
+	switch which {
+	case MainCache:
+		return g.mainCache.stats()
+	case HotCache:
+		return g.hotCache.stats()
+	case NegativeCache:
+		return g.negCache.stats()
+	default:
+		return CacheStats{}
+	}
+}
@@ -0,0 +1,96 @@
+package tscache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestGroup_Stats tests the group counters for hits, loads and errors.
+func TestGroup_Stats(t *testing.T) {
+	group := NewGroup("stats-group", 1000, GetterFunc(func(key string) ([]byte, error) {
+		if key == "missing" {
+			return nil, errors.New("not found")
+		}
+		return []byte(key), nil
+	}))
+
+	group.Get("key1")
+	group.Get("key1")
+	group.Get("missing")
+
+	want := Stats{Gets: 3, CacheHits: 1, Loads: 2, LoadsDeduped: 2, LocalLoads: 1, LocalLoadErrs: 1}
+	if got := group.Stats(); got != want {
+		t.Errorf("expected stats %+v, got %+v", want, got)
+	}
+
+	cs := group.CacheStats(MainCache)
+	if cs.Gets != 3 || cs.Hits != 1 || cs.Items != 1 || cs.Bytes != int64(len("key1")*2) {
+		t.Errorf("unexpected main cache stats %+v", cs)
+	}
+	if cs := group.CacheStats(HotCache); cs != (CacheStats{Gets: 2}) {
+		t.Errorf("unexpected hot cache stats %+v", cs)
+	}
+}
+
+// TestGroup_StatsDeduped tests that concurrent loads of one key are counted once after deduplication.
+func TestGroup_StatsDeduped(t *testing.T) {
+	release := make(chan struct{})
+	group := NewGroup("stats-dedup-group", 1000, GetterFunc(func(key string) ([]byte, error) {
+		<-release
+		return []byte(key), nil
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			group.Get("key1")
+		}()
+	}
+	for group.Stats().Loads < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give the last callers time to join the in-flight call before it completes.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if s := group.Stats(); s.Loads != 5 || s.LoadsDeduped != 1 || s.LocalLoads != 1 {
+		t.Errorf("expected 5 loads deduplicated to 1, got %+v", s)
+	}
+}
+
+// TestCache_Evictions tests that evictions are counted when the budget is exceeded.
+func TestCache_Evictions(t *testing.T) {
+	c := &cache{cacheBytes: 10}
+	c.add("k1", ByteView{B: []byte("v1")})
+	c.add("k2", ByteView{B: []byte("v2")})
+	c.add("k3", ByteView{B: []byte("v3")})
+
+	if s := c.stats(); s.Evictions != 1 || s.Items != 2 || s.Bytes != 8 {
+		t.Errorf("unexpected cache stats %+v", s)
+	}
+}
+
+// TestCache_DropReasons tests that removals and expirations are not counted as evictions.
+func TestCache_DropReasons(t *testing.T) {
+	c := &cache{cacheBytes: 100}
+	defer c.close()
+	expired := time.Now().Add(-time.Second)
+	c.add("k1", ByteView{B: []byte("v1")})
+	c.add("k2", ByteView{B: []byte("v2"), e: expired})
+	c.add("k3", ByteView{B: []byte("v3"), e: expired})
+
+	c.remove("k1")
+	if _, ok := c.get("k2"); ok {
+		t.Fatal("expected k2 to be expired")
+	}
+	c.removeExpired(time.Now())
+
+	if s := c.stats(); s.Evictions != 0 || s.Removals != 1 || s.Expirations != 2 || s.Items != 0 {
+		t.Errorf("unexpected cache stats %+v", s)
+	}
+}
@@ -0,0 +1,75 @@
+package testcluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"tscache"
+)
+
+// Origin is the data source shared by the nodes of a cluster. It counts the loads of every key,
+// so that tests can check how often the cluster reached it.
+type Origin struct {
+	Delay time.Duration // Delay is added to every load, to let concurrent requests overlap.
+
+	mu     sync.Mutex
+	values map[string]string
+	loads  map[string]int
+}
+
+// NewOrigin creates an origin holding values.
+func NewOrigin(values map[string]string) *Origin {
+	o := &Origin{values: make(map[string]string, len(values)), loads: make(map[string]int)}
+	for k, v := range values {
+		o.values[k] = v
+	}
+	return o
+}
+
+// Set sets the value of key.
+func (o *Origin) Set(key, value string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values[key] = value
+}
+
+// Get loads the value of key, honoring the context while waiting for the delay.
+// Keys without a value are reported with tscache.ErrNotFound.
+func (o *Origin) Get(ctx context.Context, key string) ([]byte, error) {
+	o.mu.Lock()
+	o.loads[key]++
+	v, ok := o.values[key]
+	o.mu.Unlock()
+
+	if o.Delay > 0 {
+		select {
+		case <-time.After(o.Delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, tscache.ErrNotFound)
+	}
+	return []byte(v), nil
+}
+
+// Loads returns the number of loads of key.
+func (o *Origin) Loads(key string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.loads[key]
+}
+
+// TotalLoads returns the number of loads of all keys.
+func (o *Origin) TotalLoads() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	total := 0
+	for _, n := range o.loads {
+		total += n
+	}
+	return total
+}
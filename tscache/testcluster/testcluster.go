@@ -0,0 +1,180 @@
+// Package testcluster runs a cluster of tscache nodes in one process for integration tests.
+//
+// Every node serves the peer protocol of an HTTPPool on its own ephemeral port and has its own
+// group registry, so that nodes do not share groups as they would through DefaultRegistry.
+// Nodes can be killed and restarted to test how the cluster behaves when peers fail.
+package testcluster
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"tscache"
+	"tscache/consistenthash"
+)
+
+// Setup creates the groups of a node in its registry. It is called when the node starts and
+// every time it restarts, so that a restarted node comes back empty, as a new process would.
+type Setup func(n *Node)
+
+// Cluster is a set of nodes that are peers of each other.
+type Cluster struct {
+	Nodes []*Node // Nodes are the nodes of the cluster, in start order.
+
+	tb    testing.TB
+	setup Setup
+}
+
+// Node is a tscache node of a Cluster.
+type Node struct {
+	Index    int               // Index is the position of the node in Cluster.Nodes.
+	Name     string            // Name is the URL of the node, its name among the peers.
+	Registry *tscache.Registry // Registry holds the groups of the node; it is replaced on restart.
+	Pool     *tscache.HTTPPool // Pool is the peer pool of the node; it is replaced on restart.
+
+	cluster *Cluster
+	addr    string // addr is the address the node listens on, kept across restarts.
+
+	mu     sync.Mutex
+	server *http.Server // server is nil while the node is killed.
+}
+
+// Start starts a cluster of n nodes, calling setup to create the groups of each node.
+// The cluster is closed when the test ends.
+func Start(tb testing.TB, n int, setup Setup) *Cluster {
+	tb.Helper()
+	c := &Cluster{tb: tb, setup: setup}
+	tb.Cleanup(c.Close)
+
+	listeners := make([]net.Listener, n)
+	for i := range listeners {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			tb.Fatalf("testcluster: listening: %v", err)
+		}
+		listeners[i] = ln
+		addr := ln.Addr().String()
+		c.Nodes = append(c.Nodes, &Node{Index: i, Name: "http://" + addr, cluster: c, addr: addr})
+	}
+	for i, node := range c.Nodes {
+		node.serve(listeners[i])
+	}
+	return c
+}
+
+// Peers returns the peer list shared by every node.
+func (c *Cluster) Peers() []*consistenthash.Node {
+	peers := make([]*consistenthash.Node, len(c.Nodes))
+	for i, node := range c.Nodes {
+		peers[i] = &consistenthash.Node{Name: node.Name}
+	}
+	return peers
+}
+
+// Owner returns the node responsible for key.
+func (c *Cluster) Owner(key string) *Node {
+	name, err := c.Nodes[0].Pool.Owner(key)
+	if err != nil {
+		c.tb.Fatalf("testcluster: owner of %s: %v", key, err)
+	}
+	for _, node := range c.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	c.tb.Fatalf("testcluster: owner %s of %s is not a node", name, key)
+	return nil
+}
+
+// Close kills every node.
+func (c *Cluster) Close() {
+	for _, node := range c.Nodes {
+		node.Kill()
+	}
+}
+
+// serve creates the node's groups and pool and serves the pool on ln.
+func (n *Node) serve(ln net.Listener) {
+	n.Registry = tscache.NewRegistry()
+	n.Pool = tscache.NewHTTPPool(n.Name, tscache.WithRegistry(n.Registry))
+	n.Pool.Set(n.cluster.Peers()...)
+	if n.cluster.setup != nil {
+		n.cluster.setup(n)
+	}
+	for _, name := range n.Registry.Names() {
+		n.Registry.Get(name).RegisterNodes(n.Pool)
+	}
+
+	server := &http.Server{Handler: n.Pool}
+	n.mu.Lock()
+	n.server = server
+	n.mu.Unlock()
+	go server.Serve(ln)
+}
+
+// Group returns the named group of the node.
+func (n *Node) Group(name string) *tscache.Group {
+	g := n.Registry.Get(name)
+	if g == nil {
+		n.cluster.tb.Fatalf("testcluster: node %d has no group %s", n.Index, name)
+	}
+	return g
+}
+
+// Get gets the value for key from the named group of the node.
+func (n *Node) Get(ctx context.Context, group, key string) (tscache.ByteView, error) {
+	return n.Group(group).GetContext(ctx, key)
+}
+
+// Alive reports whether the node is serving.
+func (n *Node) Alive() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.server != nil
+}
+
+// Kill stops the node at once, dropping the connections of peers, and closes its groups.
+// Killing a killed node does nothing.
+func (n *Node) Kill() {
+	n.mu.Lock()
+	server := n.server
+	n.server = nil
+	n.mu.Unlock()
+	if server != nil {
+		server.Close()
+		n.Registry.Close()
+	}
+}
+
+// restartAttempts bounds the attempts to listen again on a killed node's address.
+const restartAttempts = 50
+
+// Restart starts a killed node again on the same address, with a new registry and empty groups.
+func (n *Node) Restart() {
+	n.cluster.tb.Helper()
+	if n.Alive() {
+		n.cluster.tb.Fatalf("testcluster: node %d is running", n.Index)
+	}
+	var (
+		ln  net.Listener
+		err error
+	)
+	for i := 0; i < restartAttempts; i++ {
+		if ln, err = net.Listen("tcp", n.addr); err == nil {
+			n.serve(ln)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.cluster.tb.Fatalf("testcluster: restarting node %d: %v", n.Index, err)
+}
+
+// String returns the index and name of the node.
+func (n *Node) String() string {
+	return fmt.Sprintf("node %d (%s)", n.Index, n.Name)
+}
@@ -0,0 +1,105 @@
+package testcluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"tscache"
+)
+
+func startCluster(t *testing.T, origin *Origin) *Cluster {
+	return Start(t, 3, func(n *Node) {
+		n.Registry.NewGroupContext("scores", 2<<10, origin)
+	})
+}
+
+func TestCluster_Singleflight(t *testing.T) {
+	origin := NewOrigin(map[string]string{"Tom": "630"})
+	origin.Delay = 50 * time.Millisecond
+	c := startCluster(t, origin)
+
+	var wg sync.WaitGroup
+	for _, node := range c.Nodes {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(node *Node) {
+				defer wg.Done()
+				view, err := node.Get(context.Background(), "scores", "Tom")
+				if err != nil || view.String() != "630" {
+					t.Errorf("%v: Get(Tom) = %q, %v", node, view.String(), err)
+				}
+			}(node)
+		}
+	}
+	wg.Wait()
+
+	if n := origin.Loads("Tom"); n != 1 {
+		t.Fatalf("origin loads of Tom = %d, want 1", n)
+	}
+	owner := c.Owner("Tom")
+	if s := owner.Group("scores").Stats(); s.LocalLoads != 1 {
+		t.Errorf("%v: local loads = %d, want 1", owner, s.LocalLoads)
+	}
+}
+
+func TestCluster_NotFound(t *testing.T) {
+	origin := NewOrigin(nil)
+	c := startCluster(t, origin)
+
+	for _, node := range c.Nodes {
+		if _, err := node.Get(context.Background(), "scores", "unknown"); !errors.Is(err, tscache.ErrNotFound) {
+			t.Errorf("%v: Get(unknown) error = %v, want ErrNotFound", node, err)
+		}
+	}
+	if n := origin.Loads("unknown"); n != len(c.Nodes) {
+		t.Errorf("origin loads of unknown = %d, want %d", n, len(c.Nodes))
+	}
+}
+
+func TestCluster_KillRestart(t *testing.T) {
+	origin := NewOrigin(nil)
+	c := startCluster(t, origin)
+
+	// Keys owned by the node to kill, asked through another node; ownership depends on the ports
+	victim, client := c.Nodes[0], c.Nodes[1]
+	values := make(map[string]string)
+	var owned []string
+	for i := 0; len(owned) < 2; i++ {
+		k := fmt.Sprint("key", i)
+		if c.Owner(k) == victim {
+			values[k] = fmt.Sprint("value", i)
+			origin.Set(k, values[k])
+			owned = append(owned, k)
+		}
+	}
+	key := owned[0]
+
+	victim.Kill()
+	view, err := client.Get(context.Background(), "scores", key)
+	if err != nil || view.String() != values[key] {
+		t.Fatalf("Get(%s) with owner killed = %q, %v", key, view.String(), err)
+	}
+	if s := client.Group("scores").Stats(); s.PeerErrors != 1 || s.LocalLoads != 1 {
+		t.Errorf("with owner killed: peer errors = %d, local loads = %d, want 1 and 1", s.PeerErrors, s.LocalLoads)
+	}
+
+	victim.Restart()
+	if !victim.Alive() {
+		t.Fatal("restarted node is not alive")
+	}
+	if s := victim.Group("scores").Stats(); s.Gets != 0 {
+		t.Errorf("restarted node has %d gets, want an empty group", s.Gets)
+	}
+	// Another key owned by the restarted node is loaded there again
+	key = owned[1]
+	if _, err := client.Get(context.Background(), "scores", key); err != nil {
+		t.Fatalf("Get(%s) after restart: %v", key, err)
+	}
+	if s := victim.Group("scores").Stats(); s.LocalLoads != 1 {
+		t.Errorf("restarted owner: local loads = %d, want 1", s.LocalLoads)
+	}
+}
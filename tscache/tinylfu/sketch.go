@@ -0,0 +1,67 @@
+package tinylfu
+
+import "hash/maphash"
+
+// sketchDepth is the number of rows of the count-min sketch.
+const sketchDepth = 4
+
+// maxCount is the saturation value of a counter.
+const maxCount = 15
+
+// sketch is a count-min sketch estimating access frequencies with small saturating counters.
+// Once the number of increments reaches the sample size, every counter is halved so that
+// the estimates favour recent popularity.
+type sketch struct {
+	rows      [sketchDepth][]uint8 // Counters, one row per hash function
+	seeds     [sketchDepth]maphash.Seed
+	mask      uint64 // Width of a row minus one; rows have a power-of-two width
+	additions int    // Increments since the last reset
+	sample    int    // Increments that trigger a reset
+}
+
+// newSketch creates a sketch with rows of at least width counters.
+func newSketch(width int) *sketch {
+	w := 1
+	for w < width {
+		w <<= 1
+	}
+	s := &sketch{mask: uint64(w - 1), sample: 10 * w}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, w)
+		s.seeds[i] = maphash.MakeSeed()
+	}
+	return s
+}
+
+// increment records an access to key.
+func (s *sketch) increment(key string) {
+	for i := range s.rows {
+		idx := maphash.String(s.seeds[i], key) & s.mask
+		if s.rows[i][idx] < maxCount {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sample {
+		s.reset()
+	}
+}
+
+// estimate returns the estimated access frequency of key.
+func (s *sketch) estimate(key string) uint8 {
+	est := uint8(maxCount)
+	for i := range s.rows {
+		est = min(est, s.rows[i][maphash.String(s.seeds[i], key)&s.mask])
+	}
+	return est
+}
+
+// reset halves every counter.
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,251 @@
+// Package tinylfu implements a byte-bounded W-TinyLFU cache (Einziger, Friedman and Manes).
+//
+// New entries enter a small LRU window. Entries leaving the window compete for a place in the
+// main area, a segmented LRU made of a probation and a protected segment: a count-min sketch
+// estimates access frequencies and a candidate is admitted only when it is more popular than
+// the entry it would replace. Scans therefore flush the window but not the main area.
+package tinylfu
+
+import (
+	"container/list"
+	"time"
+	"tscache/policy"
+)
+
+const (
+	// windowPercent is the share of the budget given to the window.
+	windowPercent = 1
+	// protectedPercent is the share of the main area given to the protected segment.
+	protectedPercent = 80
+	// bytesPerCounter sizes the sketch from the budget, assuming small entries.
+	bytesPerCounter = 64
+	// minSketchWidth and maxSketchWidth bound the width of the sketch rows.
+	minSketchWidth = 1 << 10
+	maxSketchWidth = 1 << 20
+)
+
+// Value is a cached value that reports its size in bytes.
+type Value = policy.Value
+
+// Cache is a W-TinyLFU cache.
+type Cache struct {
+	maxBytes     int64                         // Maximum cache size in bytes; zero means unbounded
+	windowMax    int64                         // Maximum size of the window in bytes
+	mainMax      int64                         // Maximum size of the probation and protected segments together
+	protectedMax int64                         // Maximum size of the protected segment in bytes
+	window       *segment                      // Admission window of recent entries
+	probation    *segment                      // Main-area entries seen once since admission
+	protected    *segment                      // Main-area entries seen again after admission
+	sketch       *sketch                       // Frequency estimates of every accessed key
+	cache        map[string]*list.Element      // Mapping of keys to elements of any segment
+	OnEvicted    func(key string, value Value) // Called for every entry dropped from the cache
+}
+
+// segment is an LRU list with its size in bytes.
+type segment struct {
+	ll     *list.List // Entries, most recently used at the front
+	nbytes int64      // Bytes accounted to the entries
+}
+
+// entry is a cached key/value pair.
+type entry struct {
+	key    string
+	value  Value
+	size   int64     // len(key)+value.Len()
+	expire time.Time // Expiration time; zero means never
+	seg    *segment  // Segment holding the entry
+}
+
+// NewCache creates a W-TinyLFU cache bounded to maxBytes.
+func NewCache(maxBytes int64, onEvicted func(key string, value Value)) *Cache {
+	windowMax := maxBytes * windowPercent / 100
+	mainMax := maxBytes - windowMax
+	width := int(min(max(maxBytes/bytesPerCounter, minSketchWidth), maxSketchWidth))
+	return &Cache{
+		maxBytes:     maxBytes,
+		windowMax:    windowMax,
+		mainMax:      mainMax,
+		protectedMax: mainMax * protectedPercent / 100,
+		window:       &segment{ll: list.New()},
+		probation:    &segment{ll: list.New()},
+		protected:    &segment{ll: list.New()},
+		sketch:       newSketch(width),
+		cache:        make(map[string]*list.Element),
+		OnEvicted:    onEvicted,
+	}
+}
+
+// New creates a W-TinyLFU cache as a policy.Store. It satisfies policy.Policy.
+func New(maxBytes int64, onEvicted func(key string, value Value)) policy.Store {
+	return NewCache(maxBytes, onEvicted)
+}
+
+// Get returns the value for key. Every lookup, hit or miss, is recorded in the sketch.
+func (c *Cache) Get(key string) (Value, bool) {
+	c.sketch.increment(key)
+	elem, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if !e.expire.IsZero() && !time.Now().Before(e.expire) {
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.touch(elem)
+	return e.value, true
+}
+
+// Peek returns the value for key without recording the access in the sketch or the segments.
+func (c *Cache) Peek(key string) (Value, time.Time, bool) {
+	elem, ok := c.cache[key]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	e := elem.Value.(*entry)
+	if !e.expire.IsZero() && !time.Now().Before(e.expire) {
+		return nil, time.Time{}, false
+	}
+	return e.value, e.expire, true
+}
+
+// Add adds or replaces the value for key; the entry never expires.
+func (c *Cache) Add(key string, value Value) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire adds or replaces the value for key. New entries enter the window.
+// Adding is not recorded in the sketch: a key is loaded after the Get that missed it,
+// which already recorded the access.
+func (c *Cache) AddWithExpire(key string, value Value, expire time.Time) {
+	size := int64(len(key)) + int64(value.Len())
+	if elem, ok := c.cache[key]; ok {
+		e := elem.Value.(*entry)
+		e.seg.nbytes += size - e.size
+		e.value = value
+		e.size = size
+		e.expire = expire
+		c.touch(elem)
+		c.evict()
+		return
+	}
+
+	e := &entry{key: key, value: value, size: size, expire: expire, seg: c.window}
+	c.cache[key] = c.window.ll.PushFront(e)
+	c.window.nbytes += size
+	c.evict()
+}
+
+// touch records a hit on elem: window and protected entries become most recently used,
+// probation entries are promoted to the protected segment.
+func (c *Cache) touch(elem *list.Element) {
+	e := elem.Value.(*entry)
+	if e.seg != c.probation {
+		e.seg.ll.MoveToFront(elem)
+		return
+	}
+	c.move(elem, c.protected)
+	for c.maxBytes != 0 && c.protected.nbytes > c.protectedMax {
+		c.move(c.protected.ll.Back(), c.probation)
+	}
+}
+
+// evict brings every area back within its budget. Entries overflowing the window are offered
+// to the main area, which keeps either the candidate or its victim based on their frequency.
+func (c *Cache) evict() {
+	if c.maxBytes == 0 {
+		return
+	}
+	for c.window.nbytes > c.windowMax {
+		c.admit(c.window.ll.Back())
+	}
+	for c.probation.nbytes+c.protected.nbytes > c.mainMax {
+		c.removeElement(c.victim())
+	}
+}
+
+// admit moves a candidate from the window into probation if it beats the entries it displaces.
+func (c *Cache) admit(candidate *list.Element) {
+	ce := candidate.Value.(*entry)
+	for c.probation.nbytes+c.protected.nbytes+ce.size > c.mainMax {
+		victim := c.victim()
+		if victim == nil || c.sketch.estimate(ce.key) <= c.sketch.estimate(victim.Value.(*entry).key) {
+			c.removeElement(candidate)
+			return
+		}
+		c.removeElement(victim)
+	}
+	c.move(candidate, c.probation)
+}
+
+// victim returns the main-area entry to evict next, preferring the probation segment.
+func (c *Cache) victim() *list.Element {
+	if elem := c.probation.ll.Back(); elem != nil {
+		return elem
+	}
+	return c.protected.ll.Back()
+}
+
+// move moves elem to the front of the segment to, keeping the byte accounting in sync.
+func (c *Cache) move(elem *list.Element, to *segment) {
+	e := elem.Value.(*entry)
+	e.seg.ll.Remove(elem)
+	e.seg.nbytes -= e.size
+	e.seg = to
+	to.nbytes += e.size
+	c.cache[e.key] = to.ll.PushFront(e)
+}
+
+// Remove drops the entry for key, if present.
+func (c *Cache) Remove(key string) {
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// RemoveExpired drops every entry expired at now and returns how many were dropped.
+func (c *Cache) RemoveExpired(now time.Time) int {
+	removed := 0
+	for _, elem := range c.cache {
+		e := elem.Value.(*entry)
+		if !e.expire.IsZero() && !now.Before(e.expire) {
+			c.removeElement(elem)
+			removed++
+		}
+	}
+	return removed
+}
+
+// removeElement unlinks an entry and reports the eviction.
+func (c *Cache) removeElement(elem *list.Element) {
+	e := elem.Value.(*entry)
+	e.seg.ll.Remove(elem)
+	e.seg.nbytes -= e.size
+	delete(c.cache, e.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value)
+	}
+}
+
+// Walk calls fn for every entry, probation first, then the window and the protected segment,
+// each from its least recently used entry, until fn returns false.
+func (c *Cache) Walk(fn func(key string, value Value, expire time.Time) bool) {
+	for _, seg := range []*segment{c.probation, c.window, c.protected} {
+		for elem := seg.ll.Back(); elem != nil; elem = elem.Prev() {
+			e := elem.Value.(*entry)
+			if !fn(e.key, e.value, e.expire) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *Cache) Len() int {
+	return len(c.cache)
+}
+
+// Bytes returns the number of bytes held by keys and values in the cache.
+func (c *Cache) Bytes() int64 {
+	return c.window.nbytes + c.probation.nbytes + c.protected.nbytes
+}
@@ -0,0 +1,112 @@
+package tinylfu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type String string
+
+func (d String) Len() int {
+	return len(d)
+}
+
+func TestGet(t *testing.T) {
+	c := NewCache(int64(0), nil)
+	c.Add("key1", String("1234"))
+	if v, ok := c.Get("key1"); !ok || string(v.(String)) != "1234" {
+		t.Fatalf("cache hit key1=1234 failed")
+	}
+	if _, ok := c.Get("key2"); ok {
+		t.Fatalf("cache miss key2 failed")
+	}
+}
+
+func TestMissThenAdd(t *testing.T) {
+	c := NewCache(int64(1000), nil)
+	if _, ok := c.Get("key1"); ok {
+		t.Fatalf("cache miss key1 failed")
+	}
+	c.Add("key1", String("1234"))
+	if n := c.sketch.estimate("key1"); n != 1 {
+		t.Fatalf("expect a miss followed by a load to count once, got %d", n)
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(16)
+	for i := 0; i < 5; i++ {
+		s.increment("hot")
+	}
+	s.increment("cold")
+	if s.estimate("hot") < 5 || s.estimate("hot") <= s.estimate("cold") {
+		t.Fatalf("expect hot to be estimated above cold, got %d and %d", s.estimate("hot"), s.estimate("cold"))
+	}
+
+	s.reset()
+	if s.estimate("hot") > 3 {
+		t.Fatalf("expect reset to halve counters, got %d", s.estimate("hot"))
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	c := NewCache(int64(1000), nil)
+	hot := make([]string, 20)
+	for i := range hot {
+		hot[i] = fmt.Sprintf("hot%02d", i)
+		c.Add(hot[i], String("vvvvvvvvvvvvvvv"))
+		for j := 0; j < 3; j++ {
+			c.Get(hot[i])
+		}
+	}
+
+	// A scan of keys seen once must not flush the frequently used keys.
+	for i := 0; i < 1000; i++ {
+		c.Add(fmt.Sprintf("scan%04d", i), String("vvvvvvvvvvvvvvv"))
+	}
+
+	for _, key := range hot {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("hot key %s was flushed by a scan", key)
+		}
+	}
+	if c.Bytes() > 1000 {
+		t.Fatalf("budget exceeded: %d bytes", c.Bytes())
+	}
+}
+
+func TestOnEvicted(t *testing.T) {
+	evicted := 0
+	c := NewCache(int64(100), func(key string, value Value) {
+		evicted++
+	})
+	for i := 0; i < 50; i++ {
+		c.Add(fmt.Sprintf("k%02d", i), String("v"))
+	}
+	if c.Len()+evicted != 50 {
+		t.Fatalf("expect every entry to be kept or evicted, got len=%d evicted=%d", c.Len(), evicted)
+	}
+	if c.Bytes() > 100 {
+		t.Fatalf("budget exceeded: %d bytes", c.Bytes())
+	}
+}
+
+func TestExpireAndRemove(t *testing.T) {
+	now := time.Now()
+	c := NewCache(int64(0), nil)
+	c.AddWithExpire("k1", String("v1"), now.Add(-time.Second))
+	c.AddWithExpire("k2", String("v2"), now.Add(time.Hour))
+	c.Add("k3", String("v3"))
+
+	if _, ok := c.Get("k1"); ok {
+		t.Fatalf("expired k1 should miss")
+	}
+	if n := c.RemoveExpired(now.Add(2 * time.Hour)); n != 1 {
+		t.Fatalf("expect 1 expired entry, got %d", n)
+	}
+	c.Remove("k3")
+	if c.Len() != 0 || c.Bytes() != 0 {
+		t.Fatalf("expect an empty cache, got len=%d bytes=%d", c.Len(), c.Bytes())
+	}
+}
@@ -1,11 +1,16 @@
 package tscache
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"sort"
 	"sync"
-	"syscall"
-	"tscache/lru"
+	"time"
+	"tscache/compress"
+	"tscache/policy"
 	pb "tscache/tscachepb"
 )
 
@@ -22,97 +27,541 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// GetterCtx is an interface for getting data based on a key, honoring the
+// deadline and cancellation of the provided context.
+type GetterCtx interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// GetterCtxFunc is an adapter function that allows using ordinary functions as GetterCtx interfaces.
+type GetterCtxFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Get calls the GetterCtxFunc function itself.
+func (f GetterCtxFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	return f(ctx, key)
+}
+
+// getterAdapter adapts a context-unaware Getter to the GetterCtx interface.
+type getterAdapter struct {
+	getter Getter
+}
+
+// Get calls the wrapped Getter, ignoring the context.
+func (a getterAdapter) Get(_ context.Context, key string) ([]byte, error) {
+	return a.getter.Get(key)
+}
+
+// ExpiringGetter is implemented by getters that decide how long each returned value stays valid.
+// A non-positive TTL falls back to the group's default TTL.
+type ExpiringGetter interface {
+	GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
+// ExpiringGetterFunc is an adapter function that allows using ordinary functions as ExpiringGetter interfaces.
+type ExpiringGetterFunc func(ctx context.Context, key string) ([]byte, time.Duration, error)
+
+// Get calls the ExpiringGetterFunc function itself, discarding the TTL.
+func (f ExpiringGetterFunc) Get(ctx context.Context, key string) ([]byte, error) {
+	b, _, err := f(ctx, key)
+	return b, err
+}
+
+// GetWithTTL calls the ExpiringGetterFunc function itself.
+func (f ExpiringGetterFunc) GetWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	return f(ctx, key)
+}
+
+// ErrNotFound is returned by Get when the key does not exist at the origin.
+// Getters return it, possibly wrapped, to let groups with a negative cache remember the missing key.
+var ErrNotFound = errors.New("tscache: key not found")
+
 // Group represents a cache group that encapsulates a cache and its associated peers.
 type Group struct {
-	name      string     // name is the name of the cache group.
-	getter    Getter     // getter is the callback function to fetch data if it's not in the cache.
-	mainCache cache      // mainCache is the main LRU cache.
-	peers     PeerPicker // peers is the peer picker for selecting remote peers.
+	name      string        // name is the name of the cache group.
+	getter    GetterCtx     // getter is the callback function to fetch data if it's not in the cache.
+	mainCache cacher        // mainCache is the main cache for keys this node owns.
+	hotCache  cache         // hotCache holds a sample of values fetched from peers, so hot keys are not always fetched remotely.
+	negCache  cache         // negCache remembers keys the origin does not have.
+	peers     PeerPicker    // peers is the peer picker for selecting remote peers.
+	ttl       time.Duration // ttl is the default lifetime of loaded values; zero keeps them until evicted.
+
+	hotCacheBytes    int64 // hotCacheBytes is the part of the group's budget given to hotCache.
+	hotCacheSampling int   // hotCacheSampling stores one in every hotCacheSampling peer-fetched values in hotCache.
+
+	negativeTTL time.Duration // negativeTTL is how long negCache remembers a missing key; zero disables it.
+
+	softTTL   time.Duration // softTTL is the age after which values are served stale and refreshed; zero disables it.
+	refreshes chan struct{} // refreshes holds a token per background refresh in flight, bounding their number.
 
-	mu sync.Mutex       // mu is used for synchronizing access to the Group.
-	m  map[string]*call // m maps each key to its corresponding call.
+	policy        policy.Policy  // policy is the eviction policy of the caches; nil means LRU.
+	sweepInterval time.Duration  // sweepInterval is how often expired entries are reclaimed.
+	shards        int            // shards is the number of independently locked shards of mainCache.
+	codec         compress.Codec // codec compresses the values of mainCache and the responses to peers that accept it.
+
+	stats groupStats // stats holds the group's counters.
+
+	loads   flightGroup // loads deduplicates the loads of keys on this node.
+	fetches flightGroup // fetches deduplicates the fetches of keys from peers.
+}
+
+// flightGroup runs a single call per key for concurrent callers.
+type flightGroup struct {
+	mu sync.Mutex       // mu guards m and the callers and keys of each flight.
+	m  map[string]*call // m maps each key to its call in flight.
 }
 
 // call represents an in-flight or completed call to Get.
 type call struct {
-	wg  sync.WaitGroup // wg is used to wait for the completion of the call.
-	val interface{}    // val is the value returned by the call.
-	err error          // err is the error returned by the call.
+	done chan struct{} // done is closed when the call completes.
+	val  interface{}   // val is the value returned by the call.
+	err  error         // err is the error returned by the call.
+	ctx  *flight       // ctx is the context the call runs on, shared by its callers.
 }
 
-var (
-	mu     sync.RWMutex              // mu guards the groups map.
-	groups = make(map[string]*Group) // groups maps cache group names to their corresponding Group instances.
+// flight is the context of a call shared by several callers, or of the calls of one batch.
+// No single caller can cancel it: it is cancelled once every caller has given up, and expires
+// at the latest deadline of its callers, or never if one of them has no deadline.
+type flight struct {
+	context.Context                         // Context carries the values of the first caller and the cancellation.
+	cancel          context.CancelCauseFunc // cancel cancels Context with the reason the call stopped.
+
+	callers int      // callers counts the callers still waiting for the flight, guarded by flightGroup.mu.
+	keys    []string // keys are the keys of the calls running on the flight, guarded by flightGroup.mu.
+
+	mu       sync.Mutex  // mu guards deadline and timer.
+	deadline time.Time   // deadline is the latest deadline of the callers; zero means none.
+	timer    *time.Timer // timer expires the call at deadline; nil when there is no deadline.
+}
+
+// newFlight creates the context of a call started by a caller with ctx.
+func newFlight(ctx context.Context) *flight {
+	f := &flight{}
+	f.Context, f.cancel = context.WithCancelCause(context.WithoutCancel(ctx))
+	if deadline, ok := ctx.Deadline(); ok {
+		f.mu.Lock()
+		f.deadline = deadline
+		f.timer = time.AfterFunc(time.Until(deadline), f.expire)
+		f.mu.Unlock()
+	}
+	return f
+}
+
+// expire cancels the call when its deadline has passed. The timer may fire late for a deadline
+// that join has since extended, in which case the call goes on.
+func (f *flight) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.timer != nil && !time.Now().Before(f.deadline) {
+		f.cancel(context.DeadlineExceeded)
+	}
+}
+
+// join extends the deadline of the call to cover a new caller with ctx. It reports false if
+// the call is already cancelled, in which case the caller must start a new one.
+func (f *flight) join(ctx context.Context) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Context.Err() != nil {
+		return false
+	}
+	if f.timer == nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	switch {
+	case !ok:
+		f.timer.Stop()
+		f.timer, f.deadline = nil, time.Time{}
+	case deadline.After(f.deadline):
+		f.timer.Reset(time.Until(deadline))
+		f.deadline = deadline
+	}
+	return true
+}
+
+// stop releases the timer of the call and cancels its context with cause.
+func (f *flight) stop(cause error) {
+	f.mu.Lock()
+	if f.timer != nil {
+		f.timer.Stop()
+		f.timer = nil
+	}
+	f.mu.Unlock()
+	f.cancel(cause)
+}
+
+// Deadline returns the latest deadline of the callers.
+func (f *flight) Deadline() (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.deadline, !f.deadline.IsZero()
+}
+
+// Err returns context.DeadlineExceeded once the call expired, and context.Canceled once every
+// caller gave up.
+func (f *flight) Err() error {
+	if f.Context.Err() == nil {
+		return nil
+	}
+	return context.Cause(f.Context)
+}
+
+const (
+	// defaultHotCacheDivisor gives hotCache one in every defaultHotCacheDivisor bytes of the group's budget.
+	defaultHotCacheDivisor = 8
+	// defaultHotCacheSampling stores one in every defaultHotCacheSampling peer-fetched values in hotCache.
+	defaultHotCacheSampling = 10
+	// defaultMaxRefreshes is the number of concurrent background refreshes when none is configured.
+	defaultMaxRefreshes = 8
+	// refreshTimeout bounds a background refresh, which has no caller to set a deadline.
+	refreshTimeout = time.Minute
 )
 
+// Registry holds groups by name. A peer pool serves the groups of one registry, so that
+// several nodes, each with its own groups, can run in one process.
+type Registry struct {
+	mu     sync.RWMutex      // mu guards the groups map.
+	groups map[string]*Group // groups maps cache group names to their corresponding Group instances.
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{groups: make(map[string]*Group)}
+}
+
+// DefaultRegistry holds the groups created by NewGroup and NewGroupContext.
+// Pools serve it unless they are given another registry.
+var DefaultRegistry = NewRegistry()
+
 // NewGroup creates and returns a new cache Group with the specified name, cache size, and getter function.
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
+	return DefaultRegistry.NewGroup(name, cacheBytes, getter, opts...)
+}
+
+// NewGroupContext creates and returns a new cache Group whose getter receives the caller's context.
+func NewGroupContext(name string, cacheBytes int64, getter GetterCtx, opts ...GroupOption) *Group {
+	return DefaultRegistry.NewGroupContext(name, cacheBytes, getter, opts...)
+}
+
+// GetGroup returns the cache Group associated with the given name.
+func GetGroup(name string) *Group {
+	return DefaultRegistry.Get(name)
+}
+
+// GroupNames returns the names of all groups, sorted.
+func GroupNames() []string {
+	return DefaultRegistry.Names()
+}
+
+// NewGroup creates a new cache Group in the registry, replacing any group of the same name.
+func (r *Registry) NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	return r.NewGroupContext(name, cacheBytes, getterAdapter{getter: getter}, opts...)
+}
 
-	mu.Lock()
-	defer mu.Unlock()
+// NewGroupContext creates a new cache Group in the registry whose getter receives the caller's
+// context, replacing any group of the same name.
+func (r *Registry) NewGroupContext(name string, cacheBytes int64, getter GetterCtx, opts ...GroupOption) *Group {
+	if getter == nil {
+		panic("nil Getter")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	g := &Group{
-		name:   name,
-		getter: getter,
-		mainCache: cache{
-			lru:        lru.NewCache(cacheBytes, nil),
-			cacheBytes: cacheBytes,
-		},
-	}
-	groups[name] = g
+		name:             name,
+		getter:           getter,
+		hotCacheBytes:    cacheBytes / defaultHotCacheDivisor,
+		hotCacheSampling: defaultHotCacheSampling,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.softTTL > 0 && g.refreshes == nil {
+		g.refreshes = make(chan struct{}, defaultMaxRefreshes)
+	}
+	if cacheBytes > 0 && g.hotCacheBytes >= cacheBytes {
+		panic("hot cache bytes must be less than the group's cacheBytes")
+	}
+	mainBytes := cacheBytes
+	if g.hotCacheBytes > 0 {
+		mainBytes -= g.hotCacheBytes
+		g.hotCache.cacheBytes = g.hotCacheBytes
+	}
+	g.hotCache.policy = g.policy
+	g.hotCache.sweepInterval = g.sweepInterval
+	g.negCache.sweepInterval = g.sweepInterval
+	if g.shards > 1 {
+		g.mainCache = newShardedCache(g.shards, mainBytes, g.policy, g.sweepInterval, g.codec)
+	} else {
+		g.mainCache = &cache{cacheBytes: mainBytes, policy: g.policy, sweepInterval: g.sweepInterval, codec: g.codec}
+	}
+	if old, ok := r.groups[name]; ok {
+		old.Close()
+	}
+	r.groups[name] = g
 	return g
 }
 
-// GetGroup returns the cache Group associated with the given name.
-func GetGroup(name string) *Group {
-	mu.RLock()
-	g := groups[name]
-	mu.RUnlock()
+// Get returns the group of the registry with the given name, or nil.
+func (r *Registry) Get(name string) *Group {
+	r.mu.RLock()
+	g := r.groups[name]
+	r.mu.RUnlock()
 	return g
 }
 
-// Do executes the function fn if the key is not in the cache.
+// Remove drops the named group from the registry and closes it.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	g, ok := r.groups[name]
+	delete(r.groups, name)
+	r.mu.Unlock()
+	if ok {
+		g.Close()
+	}
+}
+
+// Close drops and closes every group of the registry.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	groups := r.groups
+	r.groups = make(map[string]*Group)
+	r.mu.Unlock()
+	for _, g := range groups {
+		g.Close()
+	}
+}
+
+// Names returns the names of the groups of the registry, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Name returns the name of the group.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Do executes fn once for concurrent callers with the same key and returns its results to all
+// of them, sharing the calls with the group's own loads of the key.
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
-	g.mu.Lock()
-	if g.m == nil {
-		g.m = make(map[string]*call)
+	return g.DoContext(context.Background(), key, func(context.Context) (interface{}, error) {
+		return fn()
+	})
+}
+
+// DoContext is like Do, but fn runs on a context shared by the callers, which stays alive while
+// any of them waits and expires at the latest of their deadlines. A caller whose ctx is done
+// stops waiting and gets ctx.Err(); the call is cancelled once every caller has stopped waiting.
+func (g *Group) DoContext(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return g.loads.do(ctx, key, fn)
+}
+
+// do executes fn once for concurrent callers with the same key, as described by Group.Do.
+func (fg *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	fg.mu.Lock()
+	if fg.m == nil {
+		fg.m = make(map[string]*call)
 	}
-	if c, ok := g.m[key]; ok {
-		g.mu.Unlock()
-		c.wg.Wait()
+	c, ok := fg.m[key]
+	if !ok || !c.ctx.join(ctx) {
+		c = &call{done: make(chan struct{}), ctx: newFlight(ctx)}
+		c.ctx.keys = []string{key}
+		fg.m[key] = c
+		go fg.run(key, c, fn)
+	}
+	c.ctx.callers++
+	fg.mu.Unlock()
+
+	select {
+	case <-c.done:
 		return c.val, c.err
+	case <-ctx.Done():
+		fg.leave(c.ctx)
+		return nil, ctx.Err()
 	}
+}
 
-	c := &call{}
-	g.m[key] = c
-	c.wg.Add(1)
-	g.mu.Unlock()
+// claim registers a caller loading keys as a batch. Keys with a call in flight join it; the
+// others get new calls sharing one flight, which the caller runs and completes with finish.
+// It returns that flight, nil if every key joined a call, and the call of each key.
+func (fg *flightGroup) claim(ctx context.Context, keys []string) (*flight, map[string]*call) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	if fg.m == nil {
+		fg.m = make(map[string]*call)
+	}
+	var f *flight
+	calls := make(map[string]*call, len(keys))
+	for _, key := range keys {
+		if c, ok := fg.m[key]; ok && c.ctx.join(ctx) {
+			c.ctx.callers++
+			calls[key] = c
+			continue
+		}
+		if f == nil {
+			f = newFlight(ctx)
+			f.callers++
+		}
+		c := &call{done: make(chan struct{}), ctx: f}
+		f.keys = append(f.keys, key)
+		fg.m[key] = c
+		calls[key] = c
+	}
+	return f, calls
+}
 
-	c.val, c.err = fn()
+// run executes fn for the call c and releases its waiters.
+func (fg *flightGroup) run(key string, c *call, fn func(ctx context.Context) (interface{}, error)) {
+	val, err := fn(c.ctx)
+	c.ctx.stop(context.Canceled)
+	fg.finish(key, c, val, err)
+}
 
-	c.wg.Done()
+// finish completes the call c for key with its results and releases its waiters.
+func (fg *flightGroup) finish(key string, c *call, val interface{}, err error) {
+	c.val, c.err = val, err
+	fg.mu.Lock()
+	if fg.m[key] == c {
+		delete(fg.m, key)
+	}
+	fg.mu.Unlock()
+	close(c.done)
+}
 
-	g.mu.Lock()
-	delete(g.m, key)
-	g.mu.Unlock()
+// leave removes a caller that stopped waiting from the flight f. Once no caller waits, the flight
+// is cancelled and its calls forgotten, so that later callers start new ones instead of joining them.
+func (fg *flightGroup) leave(f *flight) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	f.callers--
+	if f.callers > 0 {
+		return
+	}
+	f.stop(context.Canceled)
+	for _, key := range f.keys {
+		if c, ok := fg.m[key]; ok && c.ctx == f {
+			delete(fg.m, key)
+		}
+	}
+}
 
-	return c.val, c.err
+// inFlight reports whether a call for key is in flight.
+func (fg *flightGroup) inFlight(key string) bool {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	_, ok := fg.m[key]
+	return ok
 }
 
 // Get retrieves the value for a given key from the cache.
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext retrieves the value for a given key from the cache.
+// The context's deadline and cancellation are propagated to peers and to the getter.
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
+	return g.get(ctx, key, true)
+}
+
+// get retrieves the value for a key from the cache. A missing key is fetched from the peer
+// picked for it when route is set, and loaded on this node otherwise. Requests forwarded by
+// peers are not routed, so that a key moved off its owner by a load bound is not sent back.
+func (g *Group) get(ctx context.Context, key string, route bool) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is empty")
 	}
+	if err := ctx.Err(); err != nil {
+		return ByteView{}, err
+	}
+
+	g.stats.gets.Add(1)
+	if v, err, ok := g.lookupCache(key); ok {
+		return v, err
+	}
+
+	return g.load(ctx, key, route)
+}
 
+// lookupCache looks a key up in the group's caches. ok is false when the key must be loaded;
+// otherwise the cached value or, for a key known to be missing, ErrNotFound is returned.
+func (g *Group) lookupCache(key string) (value ByteView, err error, ok bool) {
 	if v, ok := g.mainCache.get(key); ok {
-		log.Printf("[pid:%d][%s Group] Cache hit", syscall.Getpid(), g.name)
-		return v, nil
+		g.stats.cacheHits.Add(1)
+		if v.Stale() {
+			g.stats.staleHits.Add(1)
+			g.refresh(key)
+		}
+		return v, nil, true
+	}
+	if v, ok := g.hotCache.get(key); ok {
+		g.stats.cacheHits.Add(1)
+		return v, nil, true
+	}
+	if g.negativeTTL > 0 {
+		if _, ok := g.negCache.get(key); ok {
+			g.stats.negativeHits.Add(1)
+			return ByteView{}, ErrNotFound, true
+		}
+	}
+	return ByteView{}, nil, false
+}
+
+// Remove deletes the value for a key from the whole cluster.
+// The owner of the key drops it first, then every other peer is told to drop any copy it holds.
+func (g *Group) Remove(ctx context.Context, key string) error {
+	if key == "" {
+		return fmt.Errorf("key is empty")
 	}
 
-	return g.load(key)
+	var owner PeerGetter
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			if err := g.removeFromPeer(ctx, peer, key); err != nil {
+				return err
+			}
+			owner = peer
+		}
+	}
+
+	g.localRemove(key)
+
+	if g.peers == nil {
+		return nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		errList []error
+	)
+	for _, peer := range g.peers.GetAll() {
+		if peer == owner {
+			continue
+		}
+		wg.Add(1)
+		go func(peer PeerGetter) {
+			defer wg.Done()
+			if err := g.removeFromPeer(ctx, peer, key); err != nil {
+				errMu.Lock()
+				errList = append(errList, err)
+				errMu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+	return errors.Join(errList...)
 }
 
 // RegisterNodes registers the peer picker for selecting remote peers.
@@ -123,41 +572,247 @@ func (g *Group) RegisterNodes(peers PeerPicker) {
 	g.peers = peers
 }
 
-// load loads the value for a key either from a peer or locally.
-func (g *Group) load(key string) (value ByteView, err error) {
-	data, err := g.Do(key, func() (interface{}, error) {
-		if g.peers != nil {
-			if peer, ok := g.peers.PickPeer(key); ok {
-				if value, err = g.getFromPeer(peer, key); err == nil {
-					return value, nil
-				}
-				log.Println("[TSCache] Failed to get from peer", err)
-			}
+// load loads the value for a key from the peer picked for it when route is set, and locally
+// otherwise. Fetches from peers and local loads are deduplicated apart, so that a request
+// forwarded by a peer never waits for a fetch that may be forwarded back to that peer.
+func (g *Group) load(ctx context.Context, key string, route bool) (ByteView, error) {
+	g.stats.loads.Add(1)
+	if route && g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			return g.loadFromPeer(ctx, peer, key)
 		}
-		return g.getLocally(key)
+	}
+	data, err := g.loads.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		g.stats.loadsDeduped.Add(1)
+		return g.loadLocally(ctx, key)
 	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return data.(ByteView), nil
+}
+
+// loadFromPeer fetches the value for a key from peer, falling back to a local load when the
+// peer fails.
+func (g *Group) loadFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
+	data, err := g.fetches.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		g.stats.loadsDeduped.Add(1)
+		value, err := g.getFromPeer(ctx, peer, key)
+		if err == nil {
+			g.stats.peerLoads.Add(1)
+			g.populateHotCache(key, value)
+			return value, nil
+		}
+		// The owner knows the key is missing; loading it here would not find it either
+		if errors.Is(err, ErrNotFound) {
+			g.stats.peerLoads.Add(1)
+			return ByteView{}, err
+		}
+		if ctx.Err() != nil {
+			g.stats.peerErrors.Add(1)
+			return ByteView{}, ctx.Err()
+		}
+		if errors.Is(err, ErrCircuitOpen) {
+			g.stats.peerRejects.Add(1)
+		} else {
+			g.stats.peerErrors.Add(1)
+			log.Println("[TSCache] Failed to get from peer", err)
+		}
+		return g.loads.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return g.loadLocally(ctx, key)
+		})
+	})
+	if err != nil {
+		return ByteView{}, err
+	}
+	return data.(ByteView), nil
+}
 
-	return data.(ByteView), err
+// selfLoadReporter is implemented by peer pickers that count the loads this node serves itself
+// toward its share of a load-bounded placement.
+type selfLoadReporter interface {
+	// beginSelfLoad reports the start of a local load and returns the function reporting its end.
+	beginSelfLoad() func()
+}
+
+// loadLocally loads the value for a key with the getter, counting the load against this node.
+func (g *Group) loadLocally(ctx context.Context, key string) (ByteView, error) {
+	if r, ok := g.peers.(selfLoadReporter); ok {
+		defer r.beginSelfLoad()()
+	}
+	value, err := g.getLocally(ctx, key)
+	if err != nil {
+		g.stats.localLoadErrs.Add(1)
+		return value, err
+	}
+	g.stats.localLoads.Add(1)
+	return value, nil
+}
+
+// refresh reloads a stale key in the background, unless a load of the key is already in flight
+// or the maximum number of refreshes is running. The stale value is served until the refresh
+// replaces it; a failed refresh leaves it in place until its hard expiration. A refresh is counted
+// as a local load, and toward this node's share of a load-bounded placement.
+func (g *Group) refresh(key string) {
+	if g.loads.inFlight(key) {
+		return
+	}
+	select {
+	case g.refreshes <- struct{}{}:
+	default:
+		return
+	}
+
+	g.stats.refreshes.Add(1)
+	go func() {
+		defer func() { <-g.refreshes }()
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		_, err := g.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
+			return g.loadLocally(ctx, key)
+		})
+		if err != nil {
+			g.stats.refreshErrs.Add(1)
+			log.Println("[TSCache] Failed to refresh", key, err)
+		}
+	}()
 }
 
 // getFromPeer fetches the value for a key from a remote peer.
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
-	request := &pb.Request{Group: g.name, Key: key}
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
+	request := &pb.Request{Group: g.name, Key: key, AcceptCompression: compress.Names(), Forwarded: true}
 	response := &pb.Response{}
-	err := peer.Get(request, response)
+	err := peer.Get(ctx, request, response)
 	if err != nil {
 		return ByteView{}, err
 	}
-	return ByteView{B: response.GetValue()}, nil
+	return g.fromResponse(key, response)
+}
+
+// fromResponse decodes the value for a key from a peer's Response message.
+func (g *Group) fromResponse(key string, response *pb.Response) (value ByteView, err error) {
+	if response.GetNotFound() {
+		g.addNegative(key)
+		return ByteView{}, ErrNotFound
+	}
+	if msg := response.GetError(); msg != "" {
+		return ByteView{}, errors.New(msg)
+	}
+	value = ByteView{B: response.GetValue()}
+	if name := response.GetCompression(); name != "" {
+		codec, ok := compress.Lookup(name)
+		if !ok {
+			return ByteView{}, fmt.Errorf("peer response uses unknown compression %q", name)
+		}
+		if value.B, err = codec.Decode(value.B); err != nil {
+			return ByteView{}, fmt.Errorf("decoding %s peer response: %w", name, err)
+		}
+	}
+	if expire := response.GetExpire(); expire != 0 {
+		value.e = time.Unix(0, expire)
+	}
+	return value, nil
+}
+
+// populateHotCache stores a sampled fraction of peer-fetched values in hotCache.
+func (g *Group) populateHotCache(key string, value ByteView) {
+	if g.hotCacheBytes <= 0 || g.hotCacheSampling <= 0 {
+		return
+	}
+	if g.hotCacheSampling > 1 && rand.Intn(g.hotCacheSampling) != 0 {
+		return
+	}
+	g.hotCache.add(key, value)
+}
+
+// removeFromPeer asks a remote peer to drop the value for a key.
+func (g *Group) removeFromPeer(ctx context.Context, peer PeerGetter, key string) error {
+	return peer.Remove(ctx, &pb.Request{Group: g.name, Key: key})
+}
+
+// Peek returns the value cached on this node for a key, and the cache holding it, without loading
+// the key, counting the lookup or changing its eviction order. A key in the negative cache is
+// returned with an empty value.
+func (g *Group) Peek(key string) (ByteView, CacheType, bool) {
+	if v, ok := g.mainCache.peek(key); ok {
+		return v, MainCache, true
+	}
+	if v, ok := g.hotCache.peek(key); ok {
+		return v, HotCache, true
+	}
+	if v, ok := g.negCache.peek(key); ok {
+		return v, NegativeCache, true
+	}
+	return ByteView{}, 0, false
+}
+
+// Purge drops every entry from this node's caches of the group. Peers are not told.
+func (g *Group) Purge() {
+	g.mainCache.purge()
+	g.hotCache.purge()
+	g.negCache.purge()
+}
+
+// Close drops every entry of the group's caches and stops their background sweeps. Registries
+// close the groups they drop or replace. A closed group still serves Gets, but reclaims expired
+// entries only when it finds them.
+func (g *Group) Close() {
+	g.mainCache.close()
+	g.hotCache.close()
+	g.negCache.close()
+}
+
+// localRemove drops the value for a key from this node's caches only.
+func (g *Group) localRemove(key string) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	g.negCache.remove(key)
+}
+
+// addNegative remembers that key is missing at the origin, if the negative cache is enabled.
+func (g *Group) addNegative(key string) {
+	if g.negativeTTL > 0 {
+		g.negCache.add(key, ByteView{e: expireAt(g.negativeTTL)})
+	}
 }
 
 // getLocally fetches the value for a key from the local cache or getter.
-func (g *Group) getLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+func (g *Group) getLocally(ctx context.Context, key string) (ByteView, error) {
+	var (
+		bytes []byte
+		ttl   time.Duration
+		err   error
+	)
+	if eg, ok := g.getter.(ExpiringGetter); ok {
+		bytes, ttl, err = eg.GetWithTTL(ctx, key)
+	} else {
+		bytes, err = g.getter.Get(ctx, key)
+	}
 	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			g.addNegative(key)
+		}
 		return ByteView{}, err
 	}
-	value := ByteView{B: cloneBytes(bytes)}
+	return g.addLoaded(key, bytes, ttl), nil
+}
+
+// addLoaded caches the bytes loaded by the getter for a key, expiring them after ttl or, if ttl
+// is not positive, after the group's default TTL.
+func (g *Group) addLoaded(key string, bytes []byte, ttl time.Duration) ByteView {
+	if ttl <= 0 {
+		ttl = g.ttl
+	}
+	value := ByteView{B: cloneBytes(bytes), e: expireAt(ttl), s: expireAt(g.softTTL)}
 	g.mainCache.add(key, value)
-	return value, nil
+	return value
+}
+
+// expireAt returns the expiration time for a value loaded now with the given TTL.
+// A non-positive TTL means the value never expires.
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
 }
@@ -2,10 +2,16 @@ package tscache
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	pb "tscache/tscachepb"
 )
 
 // TestGroup tests the functionality of the Group struct.
@@ -103,7 +109,7 @@ func TestGroup_GetLocally(t *testing.T) {
 	}))
 
 	// Test case: key exists in the getter.
-	byteView, err := group.getLocally("key1")
+	byteView, err := group.getLocally(context.Background(), "key1")
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -113,7 +119,7 @@ func TestGroup_GetLocally(t *testing.T) {
 	}
 
 	// Test case: key does not exist in the getter.
-	byteView, err = group.getLocally("key2")
+	byteView, err = group.getLocally(context.Background(), "key2")
 	if err == nil || len(byteView.ByteSlice()) != 0 {
 		t.Errorf("expected error, got nil, and non-empty byte view")
 	}
@@ -130,7 +136,7 @@ func TestGroup_Load(t *testing.T) {
 	}))
 
 	// Test case: key exists in the cache.
-	byteView, err := group.load("key1")
+	byteView, err := group.load(context.Background(), "key1", true)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -140,7 +146,7 @@ func TestGroup_Load(t *testing.T) {
 	}
 
 	// Test case: key does not exist in the cache or peers.
-	byteView, err = group.load("key2")
+	byteView, err = group.load(context.Background(), "key2", true)
 	if err == nil {
 		t.Errorf("expected error")
 	}
@@ -148,3 +154,512 @@ func TestGroup_Load(t *testing.T) {
 		t.Errorf("expected empty byte view, got %v", byteView.ByteSlice())
 	}
 }
+
+// TestGroup_GetContext tests that the caller's context reaches the getter and cancellation is honored.
+func TestGroup_GetContext(t *testing.T) {
+	group := NewGroupContext("ctx-group", 100, GetterCtxFunc(func(ctx context.Context, key string) ([]byte, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, errors.New("missing deadline")
+		}
+		return []byte("value-" + key), nil
+	}))
+
+	// Test case: the deadline of the caller is visible to the getter.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	view, err := group.GetContext(ctx, "key1")
+	if err != nil || view.String() != "value-key1" {
+		t.Fatalf("unexpected result %q, %v", view, err)
+	}
+
+	// Test case: a cancelled context does not trigger a load.
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := group.GetContext(cancelled, "key2"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// waitCallers waits until n callers wait for the load of key.
+func waitCallers(t *testing.T, g *Group, key string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		g.loads.mu.Lock()
+		c, ok := g.loads.m[key]
+		joined := ok && c.ctx.callers == n
+		g.loads.mu.Unlock()
+		if joined {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d callers of %s", n, key)
+}
+
+// TestGroup_Do tests that Do and DoContext share one call for concurrent callers of a key.
+func TestGroup_Do(t *testing.T) {
+	group := NewGroup("do-group", 100, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	var calls atomic.Int32
+	release := make(chan struct{})
+	done := make(chan interface{})
+	go func() {
+		v, _ := group.Do("key", func() (interface{}, error) {
+			calls.Add(1)
+			<-release
+			return "value", nil
+		})
+		done <- v
+	}()
+	waitCallers(t, group, "key", 1)
+	go func() {
+		v, _ := group.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+			calls.Add(1)
+			return "other", nil
+		})
+		done <- v
+	}()
+	waitCallers(t, group, "key", 2)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if v := <-done; v != "value" {
+			t.Errorf("caller %d got %v, want value", i, v)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+}
+
+// TestGroup_DoCancel tests that deduplicated callers each honor their own context, and that
+// cancelling the caller that started a load does not fail the others.
+func TestGroup_DoCancel(t *testing.T) {
+	var loads atomic.Int32
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	aborted := make(chan error, 1)
+	group := NewGroupContext("do-cancel", 100, GetterCtxFunc(func(ctx context.Context, key string) ([]byte, error) {
+		loads.Add(1)
+		started <- struct{}{}
+		wait := release
+		if key != "key1" {
+			wait = nil
+		}
+		select {
+		case <-wait:
+			return []byte("value-" + key), nil
+		case <-ctx.Done():
+			aborted <- ctx.Err()
+			return nil, ctx.Err()
+		}
+	}))
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := group.GetContext(leaderCtx, "key1")
+		leader <- err
+	}()
+	<-started
+
+	waiter := make(chan error, 1)
+	go func() {
+		view, err := group.GetContext(context.Background(), "key1")
+		if err == nil && view.String() != "value-key1" {
+			err = fmt.Errorf("unexpected value %q", view)
+		}
+		waiter <- err
+	}()
+	waitCallers(t, group, "key1", 2)
+
+	// Test case: a waiter with a short deadline stops waiting on time.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := group.GetContext(ctx, "key1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the short deadline to be honored, waited %v", elapsed)
+	}
+
+	// Test case: the leader is cancelled, the load goes on for the waiter.
+	cancelLeader()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled for the leader, got %v", err)
+	}
+	close(release)
+	if err := <-waiter; err != nil {
+		t.Errorf("unexpected error for the waiter: %v", err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("expected 1 load, got %d", n)
+	}
+
+	// Test case: a load blocking on key2 is cancelled once every caller gave up.
+	gone, cancelGone := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := group.GetContext(gone, "key2")
+		done <- err
+	}()
+	<-started
+	cancelGone()
+	<-done
+	select {
+	case err := <-aborted:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the abandoned load to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("abandoned load was not cancelled")
+	}
+}
+
+// TestGroup_TTL tests that values are reloaded once the group's default TTL has passed.
+func TestGroup_TTL(t *testing.T) {
+	loads := 0
+	group := NewGroup("ttl-group", 100, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}), WithTTL(50*time.Millisecond))
+
+	for i := 0; i < 2; i++ {
+		if _, err := group.Get("key1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected 1 load before expiry, got %d", loads)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, err := group.Get("key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loads != 2 {
+		t.Fatalf("expected reload after expiry, got %d loads", loads)
+	}
+}
+
+// TestGroup_PerKeyTTL tests that a TTL returned by an ExpiringGetter overrides the group default.
+func TestGroup_PerKeyTTL(t *testing.T) {
+	group := NewGroupContext("per-key-ttl-group", 100, ExpiringGetterFunc(
+		func(ctx context.Context, key string) ([]byte, time.Duration, error) {
+			if key == "short" {
+				return []byte(key), time.Minute, nil
+			}
+			return []byte(key), 0, nil
+		}), WithTTL(time.Hour))
+
+	short, err := group.Get("short")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := time.Until(short.Expire()); d <= 0 || d > time.Minute {
+		t.Errorf("expected per-key TTL of a minute, got %v", d)
+	}
+
+	long, err := group.Get("long")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := time.Until(long.Expire()); d <= time.Minute || d > time.Hour {
+		t.Errorf("expected group TTL of an hour, got %v", d)
+	}
+}
+
+// fakePeer serves values from a map and records the keys fetched and removed through it.
+type fakePeer struct {
+	mu      sync.Mutex
+	values  map[string]string
+	gets    int
+	removed []string
+}
+
+func (p *fakePeer) Get(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	v, ok := p.values[in.GetKey()]
+	if !ok {
+		return errors.New("not found")
+	}
+	out.Value = []byte(v)
+	return nil
+}
+
+func (p *fakePeer) Remove(ctx context.Context, in *pb.Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removed = append(p.removed, in.GetKey())
+	return nil
+}
+
+// fakePicker routes every key to owner and lists all peers.
+type fakePicker struct {
+	owner *fakePeer
+	all   []*fakePeer
+}
+
+func (p *fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	if p.owner == nil {
+		return nil, false
+	}
+	return p.owner, true
+}
+
+func (p *fakePicker) GetAll() []PeerGetter {
+	peers := make([]PeerGetter, 0, len(p.all))
+	for _, peer := range p.all {
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// TestRegistry_Close tests that a registry closes the groups it replaces or drops.
+func TestRegistry_Close(t *testing.T) {
+	r := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) { return []byte(key), nil })
+	closed := func(g *Group) bool { return g.mainCache.(*cache).closed }
+
+	first := r.NewGroup("registry-close", 100, getter)
+	second := r.NewGroup("registry-close", 100, getter)
+	if !closed(first) || closed(second) {
+		t.Fatalf("expected only the replaced group to be closed")
+	}
+	if r.Get("registry-close") != second {
+		t.Fatalf("expected the new group to replace the old one")
+	}
+
+	r.Remove("registry-close")
+	if !closed(second) || r.Get("registry-close") != nil {
+		t.Fatalf("expected the removed group to be dropped and closed")
+	}
+
+	third := r.NewGroup("registry-close", 100, getter)
+	r.Close()
+	if !closed(third) || len(r.Names()) != 0 {
+		t.Fatalf("expected Close to drop and close every group")
+	}
+}
+
+// TestGroup_Remove tests that Remove drops the local copy and reaches the owner and every other peer once.
+func TestGroup_Remove(t *testing.T) {
+	loads := 0
+	group := NewGroup("remove-group", 100, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte(key), nil
+	}))
+
+	if _, err := group.Get("key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	owner, other := &fakePeer{}, &fakePeer{}
+	picker := &fakePicker{owner: owner, all: []*fakePeer{owner, other}}
+	group.RegisterNodes(picker)
+
+	if err := group.Remove(context.Background(), "key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(owner.removed) != 1 || len(other.removed) != 1 {
+		t.Fatalf("expected one removal per peer, got owner=%v other=%v", owner.removed, other.removed)
+	}
+
+	// The local copy is gone, so the next Get loads again (the fake owner fails, so it loads locally).
+	if _, err := group.Get("key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loads != 2 {
+		t.Fatalf("expected reload after Remove, got %d loads", loads)
+	}
+}
+
+// TestGroup_HotCache tests that sampled peer-fetched values are served from the hot cache.
+func TestGroup_HotCache(t *testing.T) {
+	group := NewGroup("hot-group", 800, GetterFunc(func(key string) ([]byte, error) {
+		return nil, errors.New("owned by a peer")
+	}), WithHotCacheSampling(1))
+
+	owner := &fakePeer{values: map[string]string{"key1": "value1"}}
+	group.RegisterNodes(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	for i := 0; i < 3; i++ {
+		view, err := group.Get("key1")
+		if err != nil || view.String() != "value1" {
+			t.Fatalf("unexpected result %q, %v", view, err)
+		}
+	}
+	if owner.gets != 1 {
+		t.Fatalf("expected a single peer fetch, got %d", owner.gets)
+	}
+	if main := group.mainCache.(*cache); group.hotCache.cacheBytes != 100 || main.cacheBytes != 700 {
+		t.Errorf("expected a 700/100 budget split, got %d/%d", main.cacheBytes, group.hotCache.cacheBytes)
+	}
+
+	// Removing the key drops the borrowed copy too.
+	if err := group.Remove(context.Background(), "key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := group.Get("key1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if owner.gets != 2 {
+		t.Fatalf("expected a second peer fetch after Remove, got %d", owner.gets)
+	}
+}
+
+// TestGroup_HotCacheBudget tests that a budget too small to split leaves the hot cache disabled
+// rather than unbounded, and that a hot cache as large as the budget is rejected.
+func TestGroup_HotCacheBudget(t *testing.T) {
+	group := NewGroup("hot-small", 4, GetterFunc(func(key string) ([]byte, error) {
+		return nil, errors.New("owned by a peer")
+	}), WithHotCacheSampling(1))
+	owner := &fakePeer{values: map[string]string{"key1": "value1"}}
+	group.RegisterNodes(&fakePicker{owner: owner, all: []*fakePeer{owner}})
+
+	for i := 0; i < 2; i++ {
+		if _, err := group.Get("key1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if owner.gets != 2 {
+		t.Errorf("expected every Get to reach the peer, got %d fetches", owner.gets)
+	}
+	if stats := group.CacheStats(HotCache); stats.Items != 0 {
+		t.Errorf("expected an empty hot cache, got %d items", stats.Items)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a hot cache as large as the budget")
+		}
+	}()
+	NewGroup("hot-large", 100, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), WithHotCacheBytes(100))
+}
+
+// TestGroup_NegativeCache tests that missing keys are remembered for the negative TTL only.
+func TestGroup_NegativeCache(t *testing.T) {
+	loads := 0
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	})
+	group := NewGroup("negative-group", 100, getter, WithNegativeCache(50*time.Millisecond, 100))
+
+	for i := 0; i < 3; i++ {
+		if _, err := group.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("expected 1 load of the missing key, got %d", loads)
+	}
+	if stats := group.Stats(); stats.NegativeHits != 2 {
+		t.Errorf("expected 2 negative hits, got %d", stats.NegativeHits)
+	}
+	if stats := group.CacheStats(NegativeCache); stats.Items != 1 {
+		t.Errorf("expected 1 negative entry, got %d", stats.Items)
+	}
+
+	// The negative entry expires
+	time.Sleep(60 * time.Millisecond)
+	group.Get("missing")
+	if loads != 2 {
+		t.Fatalf("expected a reload after the negative TTL, got %d loads", loads)
+	}
+
+	// Remove forgets the negative entry
+	if err := group.Remove(context.Background(), "missing"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	group.Get("missing")
+	if loads != 3 {
+		t.Fatalf("expected a reload after Remove, got %d loads", loads)
+	}
+
+	// Other errors are not cached
+	group = NewGroup("negative-errors", 100, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, errors.New("origin down")
+	}), WithNegativeCache(time.Minute, 100))
+	group.Get("key")
+	group.Get("key")
+	if loads != 5 {
+		t.Fatalf("expected every failing load to reach the origin, got %d loads", loads)
+	}
+}
+
+func TestGroup_StaleWhileRevalidate(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if loads.Add(1) > 1 {
+			<-release
+		}
+		return []byte(fmt.Sprintf("%s-%d", key, loads.Load())), nil
+	})
+	group := NewGroup("swr-group", 1<<10, getter, WithTTL(200*time.Millisecond), WithStaleWhileRevalidate(20*time.Millisecond, 1))
+
+	if v, _ := group.Get("key"); v.String() != "key-1" {
+		t.Fatalf("unexpected value %q", v.String())
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Stale hits are served at once while a single refresh runs
+	for i := 0; i < 5; i++ {
+		if v, err := group.Get("key"); err != nil || v.String() != "key-1" {
+			t.Fatalf("expected the stale value, got %q, %v", v.String(), err)
+		}
+	}
+	if n := group.Stats().Refreshes; n != 1 {
+		t.Fatalf("expected a single refresh, got %d", n)
+	}
+	close(release)
+	for deadline := time.Now().Add(time.Second); len(group.refreshes) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v, _ := group.Get("key"); v.String() != "key-2" {
+		t.Fatalf("expected the refreshed value, got %q", v.String())
+	}
+	if stats := group.Stats(); stats.StaleHits != 5 || stats.Refreshes != 1 || stats.LocalLoads != 2 {
+		t.Errorf("expected 5 stale hits, 1 refresh and 2 local loads, got %+v", stats)
+	}
+
+	// Past the hard TTL, callers wait for the load
+	time.Sleep(210 * time.Millisecond)
+	if v, _ := group.Get("key"); v.String() != "key-3" {
+		t.Fatalf("expected a synchronous reload after the hard TTL, got %q", v.String())
+	}
+}
+
+func TestGroup_StaleWhileRevalidateLimit(t *testing.T) {
+	release := make(chan struct{})
+	var loads atomic.Int32
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		if loads.Add(1) > 3 {
+			<-release
+		}
+		return []byte(key), nil
+	})
+	group := NewGroup("swr-limit", 1<<10, getter, WithStaleWhileRevalidate(time.Millisecond, 2))
+	defer close(release)
+
+	for _, key := range []string{"a", "b", "c"} {
+		group.Get(key)
+	}
+	time.Sleep(5 * time.Millisecond)
+	for _, key := range []string{"a", "b", "c"} {
+		if v, err := group.Get(key); err != nil || v.String() != key {
+			t.Fatalf("expected the stale value of %s, got %q, %v", key, v.String(), err)
+		}
+	}
+	if n := group.Stats().Refreshes; n != 2 {
+		t.Errorf("expected 2 concurrent refreshes, got %d", n)
+	}
+}